@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/felixge/httpsnoop"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Logs a line per request at the INFO level, to the access log file if configured.
+// The 2xx responses may be sampled, the excluded paths aren't logged at all.
+func (app *application) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.accessLog.enabled || accessLogExcluded(app.config.accessLog.exclude, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r, state := app.contextRequestState(r)
+		m := httpsnoop.CaptureMetrics(next, w, r)
+
+		if m.Code >= 200 && m.Code < 300 && rand.Float64() >= app.config.accessLog.sample {
+			return
+		}
+
+		pattern := state.routePattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		properties := map[string]string{
+			"method":      r.Method,
+			"route":       pattern,
+			"status":      strconv.Itoa(m.Code),
+			"bytes":       strconv.FormatInt(m.Written, 10),
+			"duration_ms": strconv.FormatFloat(float64(m.Duration)/float64(time.Millisecond), 'f', 3, 64),
+			"client_ip":   state.clientIP,
+			"user_agent":  r.UserAgent(),
+		}
+		if state.user != nil && !state.user.IsAnonymous() {
+			properties["user_id"] = strconv.FormatInt(state.user.ID, 10)
+		}
+
+		// The request and trace IDs are added from the context.
+		app.accessLogger.PrintInfoContext(r.Context(), "request", properties)
+	})
+}
+
+// Reports whether the path is excluded from the access log: the exclusions ending
+// with "/" are prefixes (e.g. "/static/"), the others exact paths.
+func accessLogExcluded(exclude []string, path string) bool {
+	for _, e := range exclude {
+		if path == e || strings.HasSuffix(e, "/") && strings.HasPrefix(path, e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/tracecontext"
+	"net/http"
+)
+
+// Custom type for request context keys, to avoid collisions with other packages.
+type contextKey string
+
+const (
+	userContextKey         = contextKey("user")
+	permissionsContextKey  = contextKey("permissions")
+	sessionUserContextKey  = contextKey("sessionUser")
+	clientIPContextKey     = contextKey("clientIP")
+	apiKeyContextKey       = contextKey("apiKey")
+	requestStateContextKey = contextKey("requestState")
+	requestIDContextKey    = contextKey("requestID")
+	traceContextKey        = contextKey("trace")
+)
+
+// Returns a new copy of the request with the provided User struct added to the context.
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	if state, ok := r.Context().Value(requestStateContextKey).(*requestState); ok {
+		state.user = user
+	}
+
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the User struct from the request context. It is only used when a User
+// struct is logically expected to be there, so a missing value is an 'unexpected' error.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
+
+// Returns a new copy of the request with the permissions of the authenticated user,
+// so they are loaded once per request.
+func (app *application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the permissions of the user from the request context. Returns nil
+// for the anonymous user.
+func (app *application) contextGetPermissions(r *http.Request) data.Permissions {
+	permissions, _ := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions
+}
+
+// The user of the HTML UI, identified by the session, with its permissions.
+type sessionUser struct {
+	user        *data.User
+	permissions data.Permissions
+}
+
+// Returns a new copy of the request with the user of the session added to the
+// context. It is logged as the user of the request, unless the request is
+// authenticated by a token.
+func (app *application) contextSetSessionUser(r *http.Request, user *data.User, permissions data.Permissions) *http.Request {
+	if state, ok := r.Context().Value(requestStateContextKey).(*requestState); ok {
+		if state.user == nil || state.user.IsAnonymous() {
+			state.user = user
+		}
+	}
+
+	ctx := context.WithValue(r.Context(), sessionUserContextKey, sessionUser{user: user, permissions: permissions})
+	return r.WithContext(ctx)
+}
+
+// Retrieves the user of the session and its permissions from the request
+// context. Returns nil, if the visitor hasn't logged in.
+func (app *application) contextGetSessionUser(r *http.Request) (*data.User, data.Permissions) {
+	su, _ := r.Context().Value(sessionUserContextKey).(sessionUser)
+	return su.user, su.permissions
+}
+
+// Returns a new copy of the request with the resolved client IP address added to the context.
+func (app *application) contextSetClientIP(r *http.Request, ip string) *http.Request {
+	if state, ok := r.Context().Value(requestStateContextKey).(*requestState); ok {
+		state.clientIP = ip
+	}
+
+	ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the client IP address from the request context. Returns an empty
+// string before the realIP middleware (e.g. for the errors of outer middleware).
+func (app *application) contextGetClientIP(r *http.Request) string {
+	ip, _ := r.Context().Value(clientIPContextKey).(string)
+	return ip
+}
+
+// Returns a new copy of the request with the ID of the API key, which authenticated it.
+func (app *application) contextSetAPIKey(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the ID of the API key from the request context. Returns an empty
+// string when the request isn't authenticated with an API key.
+func (app *application) contextGetAPIKey(r *http.Request) string {
+	id, _ := r.Context().Value(apiKeyContextKey).(string)
+	return id
+}
+
+// Holds what the inner handlers learn about the request (the matched route, the
+// client IP, the user) for the outer middleware (metrics, access log), which see
+// only their own copy of the request. The outermost one adds it to the context.
+type requestState struct {
+	routePattern string
+	clientIP     string
+	user         *data.User
+}
+
+// Returns the request with the request state in the context, and the state itself.
+// The state, which is already in the context, is reused.
+func (app *application) contextRequestState(r *http.Request) (*http.Request, *requestState) {
+	if state, ok := r.Context().Value(requestStateContextKey).(*requestState); ok {
+		return r, state
+	}
+
+	state := &requestState{}
+	ctx := context.WithValue(r.Context(), requestStateContextKey, state)
+	return r.WithContext(ctx), state
+}
+
+// Sets the pattern of the matched route, if the request state is in the context.
+func (app *application) contextSetRoutePattern(r *http.Request, pattern string) {
+	if state, ok := r.Context().Value(requestStateContextKey).(*requestState); ok {
+		state.routePattern = pattern
+	}
+}
+
+// Returns a new copy of the request with the request ID added to the context.
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the request ID from the request context. Returns an empty string
+// before the traceRequest middleware.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// Returns a new copy of the request with the trace context of its handling.
+func (app *application) contextSetTrace(r *http.Request, tp tracecontext.TraceParent) *http.Request {
+	ctx := context.WithValue(r.Context(), traceContextKey, tp)
+	return r.WithContext(ctx)
+}
+
+// Retrieves the trace context from the request context, if it's there.
+func (app *application) contextGetTrace(r *http.Request) (tracecontext.TraceParent, bool) {
+	tp, ok := r.Context().Value(traceContextKey).(tracecontext.TraceParent)
+	return tp, ok
+}
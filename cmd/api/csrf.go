@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Name of the hidden form field and of the header with the CSRF token.
+const (
+	csrfFieldName  = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// Length of the per-session CSRF secret in bytes.
+const csrfSecretLength = 32
+
+// Rejects the unsafe (POST, PUT, PATCH, DELETE) requests of the HTML UI,
+// which don't carry the CSRF token of the session. Requests with a bearer
+// token or an API key are skipped: browsers don't attach these headers on their own.
+// It must be used after app.session.Enable.
+func (app *application) verifyCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || r.Header.Get("X-API-Key") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(csrfHeaderName)
+		if token == "" {
+			token = r.FormValue(csrfFieldName)
+		}
+
+		secret, err := base64.RawURLEncoding.DecodeString(app.session.GetString(r, "csrfSecret"))
+		if err != nil || len(secret) != csrfSecretLength || !csrfTokenValid(secret, token) {
+			app.csrfFailureResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Renews the session token on the privilege level change (session fixation),
+// and drops the CSRF secret, so the tokens issued before aren't valid anymore.
+// The next form gets a token of a new secret.
+func (app *application) renewSession(r *http.Request) error {
+	err := app.session.RenewToken(r)
+	if err != nil {
+		return err
+	}
+	app.session.Remove(r, "csrfSecret")
+	return nil
+}
+
+// Returns a masked CSRF token for the session, creating the session secret if needed.
+// Each call returns a different token (one-time pad || secret XOR pad), so the secret
+// can't be recovered from compressed responses (BREACH).
+func (app *application) csrfToken(r *http.Request) (string, error) {
+	secret, err := base64.RawURLEncoding.DecodeString(app.session.GetString(r, "csrfSecret"))
+	if err != nil || len(secret) != csrfSecretLength {
+		secret = make([]byte, csrfSecretLength)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return "", err
+		}
+		app.session.Put(r, "csrfSecret", base64.RawURLEncoding.EncodeToString(secret))
+	}
+
+	masked := make([]byte, 2*csrfSecretLength)
+	_, err = rand.Read(masked[:csrfSecretLength])
+	if err != nil {
+		return "", err
+	}
+	for i := range secret {
+		masked[csrfSecretLength+i] = secret[i] ^ masked[i]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(masked), nil
+}
+
+// Unmasks the token and compares it with the session secret in constant time.
+func csrfTokenValid(secret []byte, token string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) != 2*csrfSecretLength {
+		return false
+	}
+
+	unmasked := make([]byte, csrfSecretLength)
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[csrfSecretLength+i]
+	}
+
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+// The hidden form input with the CSRF token: {{csrfField .CSRFToken}}
+func csrfField(token string) template.HTML {
+	return template.HTML(`<input type='hidden' name='` + csrfFieldName + `' value='` + template.HTMLEscapeString(token) + `'>`)
+}
+
+func (app *application) csrfFailureResponse(w http.ResponseWriter, r *http.Request) {
+	app.renderStatus(w, r, http.StatusBadRequest, "csrf.page.tmpl", nil)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFTokenValid(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x5a}, csrfSecretLength)
+	other := bytes.Repeat([]byte{0xa5}, csrfSecretLength)
+
+	// Masks the secret with the pad: pad || secret XOR pad.
+	mask := func(secret []byte, pad byte) string {
+		masked := make([]byte, 2*csrfSecretLength)
+		for i := range secret {
+			masked[i] = pad
+			masked[csrfSecretLength+i] = secret[i] ^ pad
+		}
+		return base64.RawURLEncoding.EncodeToString(masked)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"masked secret", mask(secret, 0x17), true},
+		{"masked with another pad", mask(secret, 0xff), true},
+		{"another secret", mask(other, 0x17), false},
+		{"unmasked secret", base64.RawURLEncoding.EncodeToString(secret), false},
+		{"empty", "", false},
+		{"not base64", "!!!", false},
+		{"truncated", mask(secret, 0x17)[:40], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csrfTokenValid(secret, tt.token); got != tt.want {
+				t.Errorf("csrfTokenValid() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	app := newTestApplication(t)
+
+	var tokens []string
+	var secret []byte
+
+	h := app.session.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			token, err := app.csrfToken(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, token)
+		}
+
+		var err error
+		secret, err = base64.RawURLEncoding.DecodeString(app.session.GetString(r, "csrfSecret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Every token is masked differently, but all of them carry the same secret.
+	if tokens[0] == tokens[1] {
+		t.Error("the tokens are the same")
+	}
+	for _, token := range tokens {
+		if !csrfTokenValid(secret, token) {
+			t.Errorf("token %q doesn't match the session secret", token)
+		}
+	}
+}
+
+func TestCSRFSession(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name       string
+		path       string
+		wantCookie bool
+	}{
+		// Pages without forms don't create the session of an anonymous visitor.
+		{"page without forms", "/", false},
+		{"page with a form", "/user/login", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := ts.Client().Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if got := len(rs.Cookies()) != 0; got != tt.wantCookie {
+				t.Errorf("session cookie set %v; want %v", got, tt.wantCookie)
+			}
+		})
+	}
+
+	// A form without the token is rejected.
+	rs, err := ts.Client().PostForm(ts.URL+"/user/login", map[string][]string{"email": {"alice@example.com"}, "password": {"pa55word123"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d for a form without the CSRF token; want %d", rs.StatusCode, http.StatusBadRequest)
+	}
+}
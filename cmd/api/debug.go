@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"github.com/ol-ilyassov/test/internal/mailer"
+	"net/http"
+)
+
+// Lists the emails captured by the file and log mail transports.
+func (app *application) debugMailPage(w http.ResponseWriter, r *http.Request) {
+	messages, err := app.mailer.Messages()
+	if err != nil && !errors.Is(err, mailer.ErrNotCaptured) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.render(w, r, "mail.page.tmpl", &templateData{
+		Emails:         messages,
+		EmailsCaptured: err == nil,
+	})
+}
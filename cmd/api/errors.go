@@ -7,16 +7,29 @@ import (
 
 // Helper (Method) for logging an error message.
 func (app *application) logError(r *http.Request, err error) {
-	app.logger.PrintError(err, map[string]string{
+	properties := map[string]string{
 		"request_method": r.Method,
 		"request_url":    r.URL.String(),
-	})
+	}
+	if ip := app.contextGetClientIP(r); ip != "" {
+		properties["client_ip"] = ip
+	}
+
+	// The request and trace IDs are added from the context.
+	app.logger.PrintErrorContext(r.Context(), err, properties)
 }
 
 // Generic helper (Method) for sending JSON-formatted error
 // messages to the client with a given status code.
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
+	// The IDs let the client refer to the request, e.g. in a bug report.
+	if id := app.contextGetRequestID(r); id != "" {
+		env["request_id"] = id
+	}
+	if tp, ok := app.contextGetTrace(r); ok {
+		env["trace_id"] = tp.TraceIDString()
+	}
 
 	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
@@ -80,7 +93,22 @@ func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
 
+func (app *application) apiKeyNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the API keys can only be created with an authentication token"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) addressNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your IP address isn't allowed to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) corsPreflightForbiddenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the cross-origin request is not allowed for this origin, method or headers"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (app *application) createEventHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		IconId       int64  `json:"icon_id"`
+		ContactsLink int64  `json:"contacts_link"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event := &data.Events{
+		Title:        input.Title,
+		Description:  input.Description,
+		IconId:       input.IconId,
+		ContactsLink: input.ContactsLink,
+	}
+
+	v := validator.New()
+	if data.ValidateEvent(v, event); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Events.Insert(event)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.search.Add(eventDocument(event))
+
+	// Location header with the URL of the new resource.
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/events/%d", event.EventId))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"event": event}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	event, err := app.models.Events.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Supports filtering by title and creation date range, sorting and pagination:
+// GET /v1/events?title=...&created_from=2021-06-01&created_to=2021-06-30&sort=-created_time&page=1&page_size=20
+func (app *application) listEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title       string
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time // Exclusive
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.CreatedFrom = app.readTime(qs, "created_from", v)
+	input.CreatedTo = app.readTimeEnd(qs, "created_to", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	input.Filters.Sort = app.readString(qs, "sort", "event_id")
+	input.Filters.SortSafelist = []string{"event_id", "title", "created_time", "-event_id", "-title", "-created_time"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.Events.GetAll(input.Title, input.CreatedFrom, input.CreatedTo, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Partial update: only the fields provided in the request body are changed.
+func (app *application) updateEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	event, err := app.models.Events.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// If the request contains a X-Expected-Version header, verify that the event
+	// version in the database matches the expected version.
+	if r.Header.Get("X-Expected-Version") != "" {
+		if strconv.FormatInt(int64(event.Version), 10) != r.Header.Get("X-Expected-Version") {
+			app.editConflictResponse(w, r)
+			return
+		}
+	}
+
+	// Pointers are nil, when the corresponding keys are not provided.
+	var input struct {
+		Title        *string `json:"title"`
+		Description  *string `json:"description"`
+		IconId       *int64  `json:"icon_id"`
+		ContactsLink *int64  `json:"contacts_link"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Title != nil {
+		event.Title = *input.Title
+	}
+	if input.Description != nil {
+		event.Description = *input.Description
+	}
+	if input.IconId != nil {
+		event.IconId = *input.IconId
+	}
+	if input.ContactsLink != nil {
+		event.ContactsLink = *input.ContactsLink
+	}
+
+	v := validator.New()
+	if data.ValidateEvent(v, event); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Events.Update(event)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.search.Add(eventDocument(event))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Events.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.search.Remove(id)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "event successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
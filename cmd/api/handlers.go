@@ -1,6 +1,10 @@
 package main
 
 import (
+	"errors"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/forms"
+	"github.com/ol-ilyassov/test/internal/validator"
 	"net/http"
 )
 
@@ -10,3 +14,124 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	//	//Articles: articles,
 	//})
 }
+
+func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "signup.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "email", "password")
+	form.MaxLength("name", 500)
+	form.MaxLength("email", 255)
+	form.MatchesPattern("email", forms.EmailRX)
+	form.MinLength("password", 8)
+	form.MaxLength("password", 72)
+
+	if !form.Valid() {
+		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	user := &data.User{
+		Name:      form.Get("name"),
+		Email:     data.NormalizeEmail(form.Get("email")),
+		Activated: false,
+	}
+
+	err = user.Password.Set(form.Get("password"))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The same rules as for the JSON API (e.g. the password length in bytes).
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		for field, message := range v.Errors {
+			form.Errors.Add(field, "This field "+message)
+		}
+		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	err = app.createUser(r, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			form.Errors.Add("email", "Address is already in use")
+			app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Your signup was successful. Please check your email to activate the account, and log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) loginUserForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "login.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email", "password")
+	form.MatchesPattern("email", forms.EmailRX)
+
+	if !form.Valid() {
+		app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	user, err := app.authenticateUser(data.NormalizeEmail(form.Get("email")), form.Get("password"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			form.Errors.Add("generic", "Email or Password is incorrect")
+			app.render(w, r, "login.page.tmpl", &templateData{Form: form})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.renewSession(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.session.Put(r, "authenticatedUserID", int(user.ID))
+
+	http.Redirect(w, r, "/user", http.StatusSeeOther)
+}
+
+func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
+	err := app.renewSession(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.session.Remove(r, "authenticatedUserID")
+	app.session.Put(r, "flash", "You've been logged out successfully!")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
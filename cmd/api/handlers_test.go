@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var csrfTokenRX = regexp.MustCompile(`name='csrf_token' value='([^']+)'`)
+
+// Returns the CSRF token of the form on the page.
+func formCSRFToken(t *testing.T, client *http.Client, pageURL string) string {
+	t.Helper()
+
+	rs, err := client.Get(pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(rs.Body)
+	rs.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := csrfTokenRX.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("no CSRF token in the form of %s", pageURL)
+	}
+	return string(match[1])
+}
+
+func TestSessionUser(t *testing.T) {
+	app := newTestApplication(t)
+
+	var accessLog bytes.Buffer
+	app.accessLogger = jsonlog.New(&accessLog, jsonlog.LevelInfo)
+	app.config.accessLog.enabled = true
+	app.config.accessLog.sample = 1
+
+	ts := newTestServer(t, app.routes())
+	user, _ := ts.registerUser(t, app, "alice@example.com")
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ts.Client()
+	client.Jar = jar
+
+	// Log in with the form of the login page.
+	rs, err := client.PostForm(ts.URL+"/user/login", url.Values{
+		"csrf_token": {formCSRFToken(t, client, ts.URL+"/user/login")},
+		"email":      {"alice@example.com"},
+		"password":   {"pa55word123"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.Request.URL.Path != "/user" {
+		t.Fatalf("redirected to %s after the login; want /user", rs.Request.URL.Path)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		// The session user is counted in its own quota tier.
+		{"quota", "/v1/me/quota", http.StatusOK, `"tier":"user"`},
+		// The session cookie doesn't authenticate the API requests.
+		{"api", "/v1/me", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, err := client.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(rs.Body)
+			rs.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rs.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d; want %d", rs.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("got body %s; want %s in it", body, tt.wantBody)
+			}
+		})
+	}
+
+	// The pages of the session user are logged with its ID.
+	for _, line := range strings.Split(accessLog.String(), "\n") {
+		if strings.Contains(line, `"route":"/user"`) {
+			if !strings.Contains(line, `"user_id":"`+strconv.FormatInt(user.ID, 10)+`"`) {
+				t.Errorf("the access log of /user has no user_id: %s", line)
+			}
+			return
+		}
+	}
+	t.Error("no access log of /user")
+}
+
+func TestSessionRenewCSRFSecret(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.registerUser(t, app, "alice@example.com")
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ts.Client()
+	client.Jar = jar
+
+	post := func(path, token string, form url.Values) int {
+		t.Helper()
+
+		form.Set("csrf_token", token)
+		rs, err := client.PostForm(ts.URL+path, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		return rs.StatusCode
+	}
+
+	credentials := url.Values{"email": {"alice@example.com"}, "password": {"pa55word123"}}
+
+	// The token of the anonymous session isn't valid after the login.
+	anonymous := formCSRFToken(t, client, ts.URL+"/user/login")
+	if status := post("/user/login", anonymous, credentials); status != http.StatusOK {
+		t.Fatalf("login: got status %d; want %d", status, http.StatusOK)
+	}
+	if status := post("/user/logout", anonymous, url.Values{}); status != http.StatusBadRequest {
+		t.Errorf("logout with the token from before the login: got status %d; want %d", status, http.StatusBadRequest)
+	}
+
+	// The token of the user's session isn't valid after the logout.
+	authenticated := formCSRFToken(t, client, ts.URL+"/user")
+	if status := post("/user/logout", authenticated, url.Values{}); status != http.StatusOK {
+		t.Fatalf("logout: got status %d; want %d", status, http.StatusOK)
+	}
+	if status := post("/user/login", authenticated, credentials); status != http.StatusBadRequest {
+		t.Errorf("login with the token from before the logout: got status %d; want %d", status, http.StatusBadRequest)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/validator"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -104,57 +107,100 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 	return nil
 }
 
-//// Returns a string value from the query string.
-//func (app *application) readString(qs url.Values, key string, defaultValue string) string {
-//	s := qs.Get(key)
-//	if s == "" {
-//		return defaultValue
-//	}
-//	return s
-//}
-//
-//// Returns slice on the base of split string on the comma character.
-//func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
-//	csv := qs.Get(key)
-//	if csv == "" {
-//		return defaultValue
-//	}
-//	return strings.Split(csv, ",")
-//}
-//
-//// Returns int value from the query string.
-//func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
-//	s := qs.Get(key)
-//	if s == "" {
-//		return defaultValue
-//	}
-//	i, err := strconv.Atoi(s)
-//	if err != nil {
-//		v.AddError(key, "must be an integer value")
-//		return defaultValue
-//	}
-//	return i
-//}
-
-func (app *application) background(fn func()) {
-	app.wg.Add(1)
-
-	go func() {
-		defer app.wg.Done()
-
-		// Recover any panic.
-		defer func() {
-
-			if err := recover(); err != nil {
-				app.logger.PrintError(fmt.Errorf("%s", err), nil)
-			}
-		}()
-		// Execute the arbitrary parameter function.
-		fn()
-	}()
+// Returns a string value from the query string.
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// Returns slice on the base of split string on the comma character.
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+	if csv == "" {
+		return defaultValue
+	}
+	return strings.Split(csv, ",")
+}
+
+// Returns int value from the query string.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// Returns time value from the query string, which is either in RFC 3339
+// format or a date "2006-01-02". Empty value is returned as nil.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+	t, _ := app.parseTime(qs, key, v)
+	return t
+}
+
+// Returns the exclusive end of a time range from the query string: a date
+// includes the whole day (it ends at the next midnight), an RFC 3339 time
+// includes its second, as the times are stored with seconds precision.
+func (app *application) readTimeEnd(qs url.Values, key string, v *validator.Validator) *time.Time {
+	t, dateOnly := app.parseTime(qs, key, v)
+	if t == nil {
+		return nil
+	}
+
+	var end time.Time
+	if dateOnly {
+		end = t.AddDate(0, 0, 1)
+	} else {
+		end = t.Truncate(time.Second).Add(time.Second)
+	}
+	return &end
+}
+
+// Parses the time value of the query string, and reports whether it's a date only.
+func (app *application) parseTime(qs url.Values, key string, v *validator.Validator) (*time.Time, bool) {
+	s := qs.Get(key)
+	if s == "" {
+		return nil, false
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, false
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return &t, true
+	}
+	v.AddError(key, "must be a date (2006-01-02) or RFC 3339 time")
+	return nil, false
+}
+
+// Writes the email to the outbox. Call it with the transaction models of the
+// triggering change, and wakeOutbox after the commit. The dispatcher delivers it,
+// and logs its failures with the request and trace IDs of r.
+func (app *application) queueEmail(models data.Models, r *http.Request, recipient, templateFile string, emailData map[string]interface{}) error {
+	message := &data.OutboxMessage{
+		Recipient: recipient,
+		Template:  templateFile,
+		Data:      emailData,
+		RequestID: app.contextGetRequestID(r),
+	}
+	if tp, ok := app.contextGetTrace(r); ok {
+		message.TraceID = tp.TraceIDString()
+	}
+	return models.Outbox.Insert(message)
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderStatus(w, r, http.StatusOK, name, td)
+}
+
+func (app *application) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, td *templateData) {
 	ts, ok := app.templateCache[name]
 	if !ok {
 		app.serverErrorResponse(w, r, fmt.Errorf("The template %s does not exist", name))
@@ -169,6 +215,7 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 		return
 	}
 
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
 
@@ -178,8 +225,21 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	}
 
 	td.CurrentYear = time.Now().Year()
-	//td.Flash = app.session.PopString(r, "flash")
+	td.Flash = app.session.PopString(r, "flash")
+
+	td.csrfToken = func() string {
+		token, err := app.csrfToken(r)
+		if err != nil {
+			app.logError(r, err)
+		}
+		return token
+	}
 
-	//td.IsAuthenticated = app.isAuthenticated(r)
+	td.IsAuthenticated = app.isAuthenticated(r)
 	return td
 }
+
+// Reports whether the visitor of the HTML UI has logged in.
+func (app *application) isAuthenticated(r *http.Request) bool {
+	return app.session.GetInt(r, "authenticatedUserID") > 0
+}
@@ -3,13 +3,29 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"expvar"
 	"flag"
+	"fmt"
+	_ "github.com/glebarez/go-sqlite"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/ol-ilyassov/test/internal/cors"
 	"github.com/ol-ilyassov/test/internal/data"
 	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"github.com/ol-ilyassov/test/internal/mailer"
+	"github.com/ol-ilyassov/test/internal/metrics"
+	"github.com/ol-ilyassov/test/internal/ratelimit"
+	"github.com/ol-ilyassov/test/internal/realip"
+	"github.com/ol-ilyassov/test/internal/search"
+	"github.com/ol-ilyassov/test/internal/session"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"golang.org/x/crypto/bcrypt"
 	"html/template"
+	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,15 +39,33 @@ type config struct {
 	port int    // Network Port
 	env  string // Current Operating Environment
 	db   struct {
-		dsn          string // Database Connection
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  string
+		driver          string // Database Driver (mysql|postgres|sqlite)
+		dsn             string // Database Connection
+		maxOpenConns    int
+		maxIdleConns    int
+		maxIdleTime     string
+		checkMigrations bool // Refuse to start when migrations are pending
 	}
-	limiter struct {
-		rps     float64 // Request per second
-		burst   int     // Number of maximum request in single burst
-		enabled bool    // Is RateLimiter turned On
+	bcryptCost     int             // Work factor for password hashes
+	trustedProxies []string        // CIDRs of the reverse proxies, which forwarding headers are honored
+	metricsAllowed realip.Networks // Client networks, which may read /metrics and /debug/vars (all if empty)
+	limiter        struct {
+		rps      float64 // Request per second
+		burst    int     // Number of maximum request in single burst
+		enabled  bool    // Is RateLimiter turned On
+		store    string  // Rate limit store (memory|sql)
+		policies map[string]ratelimit.Policy
+		quotas   map[string]ratelimit.Policy // Daily quotas of the tiers
+	}
+	mail struct {
+		transport string // Mail Transport (smtp|file|log)
+		dir       string // Directory for the .eml files of the file transport
+	}
+	outbox struct {
+		interval    time.Duration // How often the outbox is checked for due emails
+		maxAttempts int           // Delivery attempts before an email is marked as failed
+		backoff     time.Duration // Delay before the first retry, doubled after each attempt
+		retention   time.Duration // How long the sent and failed emails are kept, 0 keeps them forever
 	}
 	smtp struct {
 		host     string
@@ -41,7 +75,18 @@ type config struct {
 		sender   string
 	}
 	cors struct {
-		trustedOrigins []string
+		policy   cors.Policy            // Set by the flags, the "default" policy
+		policies map[string]cors.Policy // Named policies of the routes, see corsPolicy()
+	}
+	session struct {
+		idleTimeout time.Duration // Session expires after this time without requests
+		lifetime    time.Duration // Session expires after this time, regardless of activity
+	}
+	accessLog struct {
+		enabled bool
+		sample  float64  // Part of the 2xx responses, which are logged
+		exclude []string // Paths (exact, or prefixes ending with "/"), which aren't logged
+		file    string   // Separate access log file, stdout if empty
 	}
 }
 
@@ -49,7 +94,17 @@ type config struct {
 type application struct {
 	config        config
 	logger        *jsonlog.Logger
+	accessLogger  *jsonlog.Logger // The logger, or the one of the access log file
 	models        data.Models
+	mailer        mailer.Mailer
+	outboxWake    chan struct{}
+	outboxSent    expvar.Int // Messages delivered since the start
+	outboxFailed  expvar.Int // Messages dead-lettered since the start
+	limiter       ratelimit.Store
+	realip        *realip.Resolver
+	registry      *metrics.Registry
+	search        *search.Index
+	session       *session.Manager
 	wg            sync.WaitGroup
 	templateCache map[string]*template.Template
 }
@@ -69,40 +124,221 @@ func main() {
 
 	//flag.StringVar(&cfg.db.dsn, "db-dsn", "root:@/daryn?parseTime=true", "MySQL DSN") //&sslmode=disable
 
+	flag.StringVar(&cfg.db.driver, "db-driver", os.Getenv("DARYN_DB_DRIVER"), "Database driver (mysql|postgres|sqlite|memory), detected from the DSN scheme by default")
+	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DARYN_DB_DSN"), "Database DSN (MySQL requires parseTime=true)")
+
 	//flag.StringVar(&cfg.db.dsn, "db-dsn", "admin_newdaryn:3D8Bc5yG1K@tcp(89.218.185.158:3306)/admin_newdaryn?parseTime=true", "MySQL DSN")
 	// 89.218.185.158:3306
 	//admin_newdaryn
 
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "PostgreSQL max open connections")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "PostgreSQL max idle connections")
-	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "PostgreSQL max connection idle time")
+	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 25, "Database max open connections")
+	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 25, "Database max idle connections")
+	flag.StringVar(&cfg.db.maxIdleTime, "db-max-idle-time", "15m", "Database max connection idle time")
+
+	flag.BoolVar(&cfg.db.checkMigrations, "db-check-migrations", false, "Refuse to start when database migrations are pending")
+
+	flag.IntVar(&cfg.bcryptCost, "bcrypt-cost", 12, "Bcrypt cost for password hashing (4-31)")
 
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate limiter maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.StringVar(&cfg.limiter.store, "limiter-store", "memory", "Rate limiter store (memory|sql), sql shares the limits between instances")
 
-	flag.StringVar(&cfg.smtp.host, "smtp-host", "smtp.mailtrap.io", "SMTP host")
+	// The "default" policy is set by -limiter-rps and -limiter-burst, and applies to the anonymous tier.
+	// The "user" and "partner" policies are the token buckets of the quota tiers.
+	limiterPolicies := flag.String("limiter-policies", "auth=10/1m static=300/1m user=20/2s partner=100/2s", "Named rate limiter policies (space separated name=LIMIT/WINDOW)")
+	limiterQuotas := flag.String("limiter-quotas", "anonymous=5000 user=50000 partner=500000", "Daily request quotas of the tiers (space separated tier=LIMIT)")
+
+	flag.StringVar(&cfg.mail.transport, "mail-transport", "log", "Mail transport (smtp|file|log), file and log are for development only")
+	flag.StringVar(&cfg.mail.dir, "mail-dir", "./mail", "Directory for the emails of the file mail transport")
+
+	flag.DurationVar(&cfg.outbox.interval, "outbox-interval", 5*time.Second, "Email outbox polling interval")
+	flag.IntVar(&cfg.outbox.maxAttempts, "outbox-max-attempts", 8, "Email delivery attempts before giving up")
+	flag.DurationVar(&cfg.outbox.backoff, "outbox-backoff", 30*time.Second, "Delay before the first email delivery retry")
+	flag.DurationVar(&cfg.outbox.retention, "outbox-retention", 7*24*time.Hour, "How long the sent and failed emails are kept (0 keeps them forever)")
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", "localhost", "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", 25, "SMTP port")
-	flag.StringVar(&cfg.smtp.username, "smtp-username", "8db0d4c74dccb8", "SMTP username")
-	flag.StringVar(&cfg.smtp.password, "smtp-password", "8376f58c61e62a", "SMTP password")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("DARYN_SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("DARYN_SMTP_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "RIG <no-reply@rig.mail.net>", "SMTP sender")
 
-	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
-		cfg.cors.trustedOrigins = strings.Fields(val)
+	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated), e.g. https://daryn.kz https://*.daryn.kz", func(val string) error {
+		cfg.cors.policy.AllowedOrigins = strings.Fields(val)
+		return cors.ValidateOrigins(cfg.cors.policy.AllowedOrigins)
+	})
+	cfg.cors.policy.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	flag.Func("cors-allowed-methods", "CORS allowed methods (space separated) (default \"GET POST PUT PATCH DELETE\")", func(val string) error {
+		cfg.cors.policy.AllowedMethods = strings.Fields(val)
+		return nil
+	})
+	cfg.cors.policy.AllowedHeaders = []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "traceparent"}
+	flag.Func("cors-allowed-headers", "CORS allowed request headers (space separated) (default \"Authorization Content-Type X-API-Key X-Request-ID traceparent\")", func(val string) error {
+		cfg.cors.policy.AllowedHeaders = strings.Fields(val)
+		return nil
+	})
+	cfg.cors.policy.ExposedHeaders = []string{"RateLimit-Limit", "RateLimit-Remaining", "Retry-After", "X-Request-ID", "traceparent"}
+	flag.Func("cors-exposed-headers", "CORS exposed response headers (space separated) (default \"RateLimit-Limit RateLimit-Remaining Retry-After X-Request-ID traceparent\")", func(val string) error {
+		cfg.cors.policy.ExposedHeaders = strings.Fields(val)
+		return nil
+	})
+	flag.BoolVar(&cfg.cors.policy.AllowCredentials, "cors-allow-credentials", false, "Allow CORS requests with credentials (cookies, Authorization)")
+	flag.DurationVar(&cfg.cors.policy.MaxAge, "cors-max-age", 10*time.Minute, "How long browsers may cache CORS preflight responses")
+
+	flag.Func("trusted-proxies", "Trusted reverse proxy CIDRs or IPs (space separated), which X-Forwarded-For, X-Real-IP and Forwarded headers are honored", func(val string) error {
+		cfg.trustedProxies = strings.Fields(val)
+		return nil
+	})
+
+	flag.Func("metrics-allowed", "Client CIDRs or IPs (space separated), which may read /metrics and /debug/vars (default: all clients)", func(val string) error {
+		var err error
+		cfg.metricsAllowed, err = realip.ParseNetworks(strings.Fields(val))
+		return err
+	})
+
+	flag.DurationVar(&cfg.session.idleTimeout, "session-idle-timeout", 30*time.Minute, "Session idle timeout (0 disables it)")
+	flag.DurationVar(&cfg.session.lifetime, "session-lifetime", 12*time.Hour, "Session absolute lifetime")
+
+	flag.BoolVar(&cfg.accessLog.enabled, "access-log", true, "Log a line per request")
+	flag.Float64Var(&cfg.accessLog.sample, "access-log-sample", 1, "Part of the 2xx responses to log (0-1), the others are always logged")
+	cfg.accessLog.exclude = []string{"/static/", "/debug/vars", "/metrics"}
+	flag.Func("access-log-exclude", "Paths not to log (space separated), the ones ending with / are prefixes (default \"/static/ /debug/vars /metrics\")", func(val string) error {
+		cfg.accessLog.exclude = strings.Fields(val)
 		return nil
 	})
+	flag.StringVar(&cfg.accessLog.file, "access-log-file", "", "Write the access log to this file instead of stdout")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: api [flags]\n       %s\n\nflags:\n", migrateUsage)
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
-	// Create Connection Pool
-	//db, err := openDB(cfg)
-	//if err != nil {
-	//	logger.PrintFatal(err, nil)
-	//}
-	//defer db.Close()
-	//logger.PrintInfo("database connection pool established", nil)
+	// bcrypt silently uses its default cost for the values out of the range.
+	if cfg.bcryptCost < bcrypt.MinCost || cfg.bcryptCost > bcrypt.MaxCost {
+		logger.PrintFatal(fmt.Errorf("bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost), nil)
+	}
+	data.PasswordCost = cfg.bcryptCost
+
+	// The file and log transports keep the emails with their activation and
+	// password reset tokens readable on the server.
+	if cfg.env == "production" && cfg.mail.transport != "smtp" {
+		logger.PrintFatal(fmt.Errorf("mail-transport %q is for development only, production requires smtp", cfg.mail.transport), nil)
+	}
+
+	if cfg.accessLog.sample < 0 || cfg.accessLog.sample > 1 {
+		logger.PrintFatal(errors.New("access-log-sample must be between 0 and 1"), nil)
+	}
+
+	accessLogger := logger
+	if cfg.accessLog.file != "" {
+		f, err := os.OpenFile(cfg.accessLog.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		defer f.Close()
+		accessLogger = jsonlog.New(f, jsonlog.LevelInfo)
+	}
+
+	var db *sql.DB
+	var models data.Models
+	var sessionStore session.Store
+	var limiterStore ratelimit.Store
+
+	// Prometheus metrics of /metrics, next to the expvar ones of /debug/vars.
+	registry := metrics.NewRegistry()
+	registry.RegisterRuntime()
+
+	policies, err := parseLimiterPolicies(cfg.limiter.rps, cfg.limiter.burst, *limiterPolicies)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	cfg.limiter.policies = policies
+
+	cfg.limiter.quotas, err = parseLimiterQuotas(*limiterQuotas)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	cfg.cors.policies, err = corsPolicies(cfg.cors.policy)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	driver, dsn, err := resolveDriver(cfg.env, cfg.db.driver, cfg.db.dsn)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	cfg.db.driver, cfg.db.dsn = driver, dsn
+
+	if cfg.db.driver == "memory" {
+		if flag.Arg(0) == "migrate" {
+			logger.PrintFatal(errors.New("migrations are not supported by the memory driver"), nil)
+		}
+		models = data.NewMemoryModels()
+		sessionStore = session.NewMemoryStore(time.Minute)
+
+		if cfg.limiter.store == "sql" {
+			logger.PrintFatal(errors.New("the sql rate limiter store requires a database driver"), nil)
+		}
+		logger.PrintInfo("using in-memory data models", nil)
+	} else {
+		dialect, err := data.ParseDialect(cfg.db.driver)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		// Create Connection Pool
+		db, err = openDB(cfg)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		defer db.Close()
+		logger.PrintInfo("database connection pool established", map[string]string{
+			"driver": cfg.db.driver,
+		})
+
+		// The "migrate" subcommand runs instead of the server.
+		if flag.Arg(0) == "migrate" {
+			err = runMigrate(db, cfg.db.driver, logger, flag.Args()[1:])
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+			return
+		}
+
+		if cfg.db.checkMigrations {
+			err = checkMigrations(db, cfg.db.driver)
+			if err != nil {
+				logger.PrintFatal(err, nil)
+			}
+		}
+
+		models = data.NewModels(db, dialect)
+		sessionStore = session.NewSQLStore(db, cfg.db.driver, 5*time.Minute)
+
+		if cfg.limiter.store == "sql" {
+			limiterStore = ratelimit.NewSQLStore(db, cfg.db.driver, time.Minute)
+		}
+
+		// DB pool Statistics
+		expvar.Publish("database", expvar.Func(func() interface{} {
+			return db.Stats()
+		}))
+
+		registry.NewGaugeFunc("db_open_connections", "Number of established database connections, in use and idle.", func() float64 {
+			return float64(db.Stats().OpenConnections)
+		})
+		registry.NewGaugeFunc("db_in_use_connections", "Number of database connections currently in use.", func() float64 {
+			return float64(db.Stats().InUse)
+		})
+		registry.NewCounterFunc("db_wait_count_total", "Total number of waits for a database connection.", func() float64 {
+			return float64(db.Stats().WaitCount)
+		})
+	}
 
 	// Npw: cmdline, memstats, version.
 	expvar.NewString("version").Set(version)
@@ -112,16 +348,36 @@ func main() {
 		return runtime.NumGoroutine()
 	}))
 
-	// DB pool Statistics
-	//expvar.Publish("database", expvar.Func(func() interface{} {
-	//	return db.Stats()
-	//}))
-
 	// Current Unix timestamp
 	expvar.Publish("timestamp", expvar.Func(func() interface{} {
 		return time.Now().Unix()
 	}))
 
+	mail, err := newMailer(cfg, logger)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	switch cfg.limiter.store {
+	case "memory":
+		limiterStore = ratelimit.NewMemoryStore(time.Minute)
+	case "sql":
+		// Created with the database connection pool above.
+	default:
+		logger.PrintFatal(fmt.Errorf("unsupported rate limiter store %q", cfg.limiter.store), nil)
+	}
+
+	resolver, err := realip.New(cfg.trustedProxies)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	// Sessions of the HTML UI.
+	sessionManager := session.New(sessionStore)
+	sessionManager.IdleTimeout = cfg.session.idleTimeout
+	sessionManager.Lifetime = cfg.session.lifetime
+	sessionManager.Cookie.Secure = cfg.env == "production"
+
 	// Template Cache init
 	templateCache, err := newTemplateCache("./ui/html/")
 	if err != nil {
@@ -132,23 +388,202 @@ func main() {
 	app := &application{
 		config:        cfg,
 		logger:        logger,
-		models:        data.NewModels(),
+		accessLogger:  accessLogger,
+		models:        models,
+		mailer:        mail,
+		outboxWake:    make(chan struct{}, 1),
+		limiter:       limiterStore,
+		realip:        resolver,
+		registry:      registry,
+		search:        search.New(),
+		session:       sessionManager,
 		templateCache: templateCache,
 	}
 
+	sessionManager.ErrorFunc = app.serverErrorResponse
+
+	// Email outbox: the pending messages in the table, and the counters of the
+	// sent and failed ones, which the purge of the finished messages doesn't reset.
+	outboxVars := expvar.NewMap("outbox")
+	outboxVars.Set("pending", expvar.Func(func() interface{} {
+		counts, err := app.models.Outbox.Counts()
+		if err != nil {
+			return err.Error()
+		}
+		return counts[data.OutboxPending]
+	}))
+	outboxVars.Set("sent", &app.outboxSent)
+	outboxVars.Set("failed", &app.outboxFailed)
+
+	// Build the full-text search index.
+	err = app.indexEvents()
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	logger.PrintInfo("search index built", map[string]string{
+		"documents": strconv.Itoa(app.search.Len()),
+	})
+
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
 	}
 }
 
+// Creates the mailer with the configured transport. The file and log
+// transports capture emails locally, so no real SMTP server is needed.
+func newMailer(cfg config, logger *jsonlog.Logger) (mailer.Mailer, error) {
+	var transport mailer.Transport
+
+	switch cfg.mail.transport {
+	case "smtp":
+		transport = mailer.NewSMTPTransport(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password)
+	case "file":
+		t, err := mailer.NewFileTransport(cfg.mail.dir)
+		if err != nil {
+			return mailer.Mailer{}, err
+		}
+		transport = t
+	case "log":
+		transport = mailer.NewLogTransport(logger)
+	default:
+		return mailer.Mailer{}, fmt.Errorf("unsupported mail transport %q", cfg.mail.transport)
+	}
+
+	return mailer.New(transport, cfg.smtp.sender), nil
+}
+
+// Returns the rate limiter policies: "default" with the given rps and burst,
+// and the named ones from "name=LIMIT/WINDOW ..." (which may override "default").
+func parseLimiterPolicies(rps float64, burst int, specs string) (map[string]ratelimit.Policy, error) {
+	if rps <= 0 || burst < 1 {
+		return nil, errors.New("limiter-rps must be positive and limiter-burst at least 1")
+	}
+
+	// A token bucket of the burst size, refilled at rps. The window has the
+	// same lower bound as the ones of ParsePolicy: the SQL store counts in ms.
+	window := time.Duration(float64(burst) / rps * float64(time.Second))
+	if window < time.Millisecond {
+		return nil, fmt.Errorf("limiter-burst / limiter-rps must be at least 1ms, got %s", window)
+	}
+
+	policies := map[string]ratelimit.Policy{
+		"default": {
+			Name:   "default",
+			Limit:  burst,
+			Window: window,
+		},
+	}
+
+	for _, spec := range strings.Fields(specs) {
+		policy, err := ratelimit.ParsePolicy(spec)
+		if err != nil {
+			return nil, err
+		}
+		policies[policy.Name] = policy
+	}
+	return policies, nil
+}
+
+// Returns the daily quotas of the tiers from "tier=LIMIT ...". Tiers without
+// a quota are limited by their token bucket only.
+func parseLimiterQuotas(specs string) (map[string]ratelimit.Policy, error) {
+	quotas := make(map[string]ratelimit.Policy)
+
+	for _, spec := range strings.Fields(specs) {
+		policy, err := ratelimit.ParsePolicy(spec + "/24h")
+		if err != nil || !validator.In(policy.Name, quotaTiers...) {
+			return nil, fmt.Errorf("invalid rate limiter quota %q, expected tier=LIMIT with tier in %s", spec, strings.Join(quotaTiers, "|"))
+		}
+		tier := policy.Name
+		policy.Name = tier + "-daily"
+		quotas[tier] = policy
+	}
+	return quotas, nil
+}
+
+// Returns the named CORS policies of the routes: "default" is the configured one,
+// "static" lets any origin load the static files, and "none" (like any unknown
+// name) allows no cross-origin requests.
+func corsPolicies(policy cors.Policy) (map[string]cors.Policy, error) {
+	// With credentials, "*" would let any site act on behalf of the users.
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" && policy.AllowCredentials {
+			return nil, errors.New("cors-allow-credentials can't be used with the \"*\" trusted origin")
+		}
+	}
+
+	return map[string]cors.Policy{
+		"default": policy,
+		"static": {
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{http.MethodGet, http.MethodHead},
+			MaxAge:         policy.MaxAge,
+		},
+	}, nil
+}
+
+// Returns the driver name and the DSN in the form expected by the driver.
+// If no driver is given, it is detected from the DSN scheme:
+// "postgres://" or "postgresql://", "mysql://", "sqlite://" or "file:".
+// DSNs without a scheme are treated as MySQL ones. Without a driver and a DSN,
+// the development environment uses the in-memory models, e.g. for go run ./cmd/api.
+func resolveDriver(env, driver, dsn string) (string, string, error) {
+	if driver == "" {
+		switch {
+		case dsn == "" && env == "development":
+			driver = "memory"
+		case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+			driver = "postgres"
+		case strings.HasPrefix(dsn, "sqlite://"), strings.HasPrefix(dsn, "file:"):
+			driver = "sqlite"
+		default:
+			driver = "mysql"
+		}
+	}
+
+	switch driver {
+	case "mysql":
+		// The MySQL driver doesn't accept the URL scheme.
+		dsn = strings.TrimPrefix(dsn, "mysql://")
+	case "memory":
+		// The in-memory models don't use a DSN.
+		return driver, "", nil
+	case "postgres":
+	case "sqlite":
+		dsn = strings.TrimPrefix(dsn, "sqlite://")
+		if dsn == "" {
+			dsn = "file:daryn.db"
+		}
+		// Enforce foreign keys (ON DELETE CASCADE) on every connection.
+		if !strings.Contains(dsn, "foreign_keys") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&_pragma=foreign_keys(1)"
+			} else {
+				dsn += "?_pragma=foreign_keys(1)"
+			}
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	if dsn == "" {
+		return "", "", fmt.Errorf("database DSN must be provided for the %s driver", driver)
+	}
+	return driver, dsn, nil
+}
+
 func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("mysql", cfg.db.dsn)
+	db, err := sql.Open(cfg.db.driver, cfg.db.dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	// SQLite allows a single writer, so concurrent connections would fail with "database is locked".
+	if cfg.db.driver == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
 	db.SetMaxIdleConns(cfg.db.maxIdleConns)
 
 	duration, err := time.ParseDuration(cfg.db.maxIdleTime)
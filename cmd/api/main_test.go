@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestResolveDriver(t *testing.T) {
+	tests := []struct {
+		env        string
+		driver     string
+		dsn        string
+		wantDriver string
+		wantDSN    string
+		wantErr    bool
+	}{
+		{"development", "", "", "memory", "", false},
+		{"production", "", "", "", "", true},
+		{"development", "mysql", "", "", "", true},
+		{"development", "postgres", "", "", "", true},
+		{"development", "", "mysql://root:@/daryn?parseTime=true", "mysql", "root:@/daryn?parseTime=true", false},
+		{"production", "", "postgres://localhost/daryn", "postgres", "postgres://localhost/daryn", false},
+		{"production", "", "sqlite:///tmp/daryn.db", "sqlite", "/tmp/daryn.db?_pragma=foreign_keys(1)", false},
+		{"development", "sqlite", "", "sqlite", "file:daryn.db?_pragma=foreign_keys(1)", false},
+		{"production", "memory", "", "memory", "", false},
+		{"development", "oracle", "oracle://localhost", "", "", true},
+	}
+
+	for _, tt := range tests {
+		driver, dsn, err := resolveDriver(tt.env, tt.driver, tt.dsn)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveDriver(%q, %q, %q): got error %v; want error %t", tt.env, tt.driver, tt.dsn, err, tt.wantErr)
+			continue
+		}
+		if driver != tt.wantDriver || dsn != tt.wantDSN {
+			t.Errorf("resolveDriver(%q, %q, %q): got %q, %q; want %q, %q", tt.env, tt.driver, tt.dsn, driver, dsn, tt.wantDriver, tt.wantDSN)
+		}
+	}
+}
@@ -1,17 +1,84 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"expvar"
 	"fmt"
 	"github.com/felixge/httpsnoop"
-	"golang.org/x/time/rate"
-	"net"
+	"github.com/ol-ilyassov/test/internal/cors"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"github.com/ol-ilyassov/test/internal/metrics"
+	"github.com/ol-ilyassov/test/internal/ratelimit"
+	"github.com/ol-ilyassov/test/internal/tracecontext"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"math"
 	"net/http"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 )
 
+// Accepts the X-Request-ID of the client (or of a proxy) or generates one, and
+// continues the W3C trace of the traceparent header or starts a new one. Both are
+// echoed in the response headers, and added to the error envelopes and to the
+// log entries of the request.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			b := make([]byte, 16)
+			_, err := rand.Read(b)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			requestID = hex.EncodeToString(b)
+		}
+
+		// The handling of the request is a new span of the caller's trace.
+		tp, err := tracecontext.Parse(r.Header.Get("traceparent"))
+		if err == nil {
+			tp, err = tp.Child()
+		} else {
+			tp, err = tracecontext.New()
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set("traceparent", tp.String())
+
+		r = app.contextSetRequestID(r, requestID)
+		r = app.contextSetTrace(r, tp)
+		r = r.WithContext(jsonlog.NewContext(r.Context(), map[string]string{
+			"request_id": requestID,
+			"trace_id":   tp.TraceIDString(),
+			"span_id":    tp.SpanIDString(),
+		}))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The request IDs of the clients are accepted, if they are short and can't
+// break the headers or the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:+/=", c)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -26,201 +93,415 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// Resolves the client IP address (see realip.Resolver) and adds it to the request context.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = app.contextSetClientIP(r, app.realip.ClientIP(r))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Limits the requests of each client IP address by the policy of the route
+// (see rateLimitPolicy), and reports the limit in the RateLimit-Limit and
+// RateLimit-Remaining headers, and the wait in the Retry-After header of a 429.
 func (app *application) rateLimit(next http.Handler) http.Handler {
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time
-	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.limiter.enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	// Hold the clients' IP addresses and rate limiters.
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
-
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			mu.Lock()
-			// Loop through all clients. If they haven't been seen within the last three
-			// minutes, delete the corresponding entry from the map.
-			for ip, client := range clients {
-				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
-			}
-			mu.Unlock()
+		// The login, account creation and static files are limited per IP address,
+		// everything else by the quota tier of the client.
+		name := rateLimitPolicy(r)
+		if name == "none" {
+			next.ServeHTTP(w, r)
+			return
 		}
-	}()
+		policy, ok := app.config.limiter.policies[name]
+		if !ok {
+			name, policy = "default", app.config.limiter.policies["default"]
+		}
+		key := app.contextGetClientIP(r)
 
-	// This is a closure, which 'closes over' the limiter variable.
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var quota ratelimit.Policy
+		if name == "default" {
+			tier := app.quotaTier(r)
+			policy, quota, key = app.tierPolicy(tier), app.config.limiter.quotas[tier], app.quotaKey(r)
+		}
+
+		result, err := app.limiter.Allow(policy, key)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 
-		if app.config.limiter.enabled {
-			// Extract the client's IP address from the request.
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		// The daily quota is counted only for the requests, which passed the token bucket.
+		if result.Allowed && quota.Limit != 0 {
+			daily, err := app.limiter.Allow(quota, key)
 			if err != nil {
 				app.serverErrorResponse(w, r, err)
 				return
 			}
-			mu.Lock()
-			// If IP address dont exist in map, then initialize a new rate limiter
-			//and add the IP address and limiter to the map.
-			if _, found := clients[ip]; !found {
-				clients[ip] = &client{
-					limiter: rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst),
-				}
+			if !daily.Allowed {
+				result = daily
 			}
+		}
 
-			clients[ip].lastSeen = time.Now()
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
 
-			// Call the Allow() method on the rate limiter for the current IP address.
-			// If not allowed, then 429 Too Many Requests response.
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock()
+		// If not allowed, then 429 Too Many Requests response.
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			app.rateLimitExceededResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Auth User with token
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+		// Retrieve the value of the Authorization header from the request.
+		authorizationHeader := r.Header.Get("Authorization")
+		apiKey := r.Header.Get("X-API-Key")
+		// Add the AnonymousUser to the request context.
+		if authorizationHeader == "" && apiKey == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The clients, which keep sending invalid credentials, are rejected
+		// before the token lookup.
+		if policy, ok := app.authFailurePolicy(); ok {
+			result, err := app.limiter.Peek(policy, app.contextGetClientIP(r))
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
 				app.rateLimitExceededResponse(w, r)
 				return
 			}
+		}
+
+		// The bearer token takes precedence over the API key.
+		scope, token := data.ScopeAPIKey, apiKey
+		if authorizationHeader != "" {
+			headerParts := strings.Split(authorizationHeader, " ")
+			if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+				app.authenticationFailed(w, r)
+				return
+			}
+			scope, token = data.ScopeAuthentication, headerParts[1]
+		}
 
-			mu.Unlock()
+		v := validator.New()
+		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+			app.authenticationFailed(w, r)
+			return
 		}
+		// Retrieve the details of the user associated with the token.
+		user, err := app.models.Users.GetForToken(scope, token)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.authenticationFailed(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		// Add the user information to the request context.
+		r = app.contextSetUser(r, user)
+
+		// The permissions are used by the rate limiter and the permission checks.
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		r = app.contextSetPermissions(r, permissions)
+
+		// The requests of an API key are limited on their own, apart from the
+		// other keys and the tokens of the user. The key is identified by the
+		// prefix of its hash, so the plaintext never leaves this function.
+		if scope == data.ScopeAPIKey {
+			hash := sha256.Sum256([]byte(token))
+			r = app.contextSetAPIKey(r, hex.EncodeToString(hash[:8]))
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
-// Auth User with token
-//func (app *application) authenticate(next http.Handler) http.Handler {
-//	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-//		w.Header().Add("Vary", "Authorization")
-//		// Retrieve the value of the Authorization header from the request.
-//		authorizationHeader := r.Header.Get("Authorization")
-//		// Add the AnonymousUser to the request context.
-//		if authorizationHeader == "" {
-//			r = app.contextSetUser(r, data.AnonymousUser)
-//			next.ServeHTTP(w, r)
-//			return
-//		}
-//
-//		headerParts := strings.Split(authorizationHeader, " ")
-//		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-//			app.invalidAuthenticationTokenResponse(w, r)
-//			return
-//		}
-//
-//		token := headerParts[1]
-//
-//		v := validator.New()
-//		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
-//			app.invalidAuthenticationTokenResponse(w, r)
-//			return
-//		}
-//		// Retrieve the details of the user associated with the authentication token.
-//		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
-//		if err != nil {
-//			switch {
-//			case errors.Is(err, data.ErrRecordNotFound):
-//				app.invalidAuthenticationTokenResponse(w, r)
-//			default:
-//				app.serverErrorResponse(w, r, err)
-//			}
-//			return
-//		}
-//		// Add the user information to the request context.
-//		r = app.contextSetUser(r, user)
-//
-//		next.ServeHTTP(w, r)
-//	})
-//}
-//
-//func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
-//	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-//		user := app.contextGetUser(r)
-//		// Is User Anonymous?
-//		if user.IsAnonymous() {
-//			app.authenticationRequiredResponse(w, r)
-//			return
-//		}
-//		next.ServeHTTP(w, r)
-//	})
-//}
-//
-//// Checks that a user is both authenticated and activated.
-//func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
-//	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-//		user := app.contextGetUser(r)
-//		// Check that a user is activated.
-//		if !user.Activated {
-//			app.inactiveAccountResponse(w, r)
-//			return
-//		}
-//		next.ServeHTTP(w, r)
-//	})
-//	// Wrap with requireAuthenticatedUser().
-//	return app.requireAuthenticatedUser(fn)
-//}
-//
-//func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
-//	fn := func(w http.ResponseWriter, r *http.Request) {
-//		user := app.contextGetUser(r)
-//		// Get the slice of permissions for the user.
-//		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
-//		if err != nil {
-//			app.serverErrorResponse(w, r, err)
-//			return
-//		}
-//		if !permissions.Include(code) {
-//			app.notPermittedResponse(w, r) // 403 Forbidden response.
-//			return
-//		}
-//		// Next handler in the chain.
-//		next.ServeHTTP(w, r)
-//	}
-//	// Wrap with requireActivatedUser().
-//	return app.requireActivatedUser(fn)
-//}
+// Identifies the logged in user of the HTML UI by the session cookie, for the
+// access log and the quota tier of the rate limiter. It doesn't authenticate
+// the API requests, which would need the CSRF protection then: only the pages
+// read the session user. app.session.Enable reuses the session loaded here.
+func (app *application) identifySessionUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie(app.session.Cookie.Name); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r, err := app.session.Load(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		userID := app.session.GetInt(r, "authenticatedUserID")
+		if userID == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.models.Users.GetByID(int64(userID))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				// The user has been deleted since the login, the pages log it out.
+				next.ServeHTTP(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		r = app.contextSetSessionUser(r, user, permissions)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Returns the policy of the failed authentications: they are limited per IP
+// address like the login attempts (by the "auth" policy), in buckets of their own.
+func (app *application) authFailurePolicy() (ratelimit.Policy, bool) {
+	policy, ok := app.config.limiter.policies["auth"]
+	if !app.config.limiter.enabled || !ok {
+		return ratelimit.Policy{}, false
+	}
+	policy.Name = "auth-failures"
+	return policy, true
+}
+
+// Counts the failed authentication of the client IP address, and sends the
+// 401 Unauthorized response.
+func (app *application) authenticationFailed(w http.ResponseWriter, r *http.Request) {
+	if policy, ok := app.authFailurePolicy(); ok {
+		_, err := app.limiter.Allow(policy, app.contextGetClientIP(r))
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	app.invalidAuthenticationTokenResponse(w, r)
+}
+
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		// Is User Anonymous?
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Checks that a user is both authenticated and activated.
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		// Check that a user is activated.
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+	// Wrap with requireAuthenticatedUser().
+	return app.requireAuthenticatedUser(fn)
+}
+
+// Allows only the clients from the networks of -metrics-allowed, if it's set,
+// so the metrics and the runtime details aren't public.
+func (app *application) requireMetricsAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(app.config.metricsAllowed) != 0 && !app.config.metricsAllowed.Contains(app.contextGetClientIP(r)) {
+			app.addressNotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Checks that the activated user is authenticated by a bearer token, not by an
+// API key, so a leaked key can't mint new keys.
+func (app *application) requireAuthenticationToken(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAPIKey(r) != "" {
+			app.apiKeyNotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	// Wrap with requireActivatedUser().
+	return app.requireActivatedUser(fn)
+}
+
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		// Get the slice of permissions for the user, loaded by app.authenticate.
+		permissions := app.contextGetPermissions(r)
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r) // 403 Forbidden response.
+			return
+		}
+		// Next handler in the chain.
+		next.ServeHTTP(w, r)
+	}
+	// Wrap with requireActivatedUser().
+	return app.requireActivatedUser(fn)
+}
+
+// requirePagePermission is requirePermission of the HTML pages, which
+// authenticate the users by the session: the anonymous visitors are sent to
+// the login page.
+func (app *application) requirePagePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The user and the permissions are loaded by app.identifySessionUser.
+		user, permissions := app.contextGetSessionUser(r)
+		if user == nil {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		if !permissions.Include(code) {
+			app.renderStatus(w, r, http.StatusForbidden, "forbidden.page.tmpl", nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
 
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
 		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+
 		origin := r.Header.Get("Origin")
-		if origin != "" && len(app.config.cors.trustedOrigins) != 0 {
-			for i := range app.config.cors.trustedOrigins {
-				if origin == app.config.cors.trustedOrigins[i] {
-					w.Header().Set("Access-Control-Allow-Origin", origin)
-					// If request has the HTTP method OPTIONS and "Access-Control-Request-Method" header,
-					// then it as a preflight request.
-					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-						// Set the necessary preflight response headers
-						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-						w.WriteHeader(http.StatusOK)
-						return
-					}
-				}
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// The policy of the route, which may override the configured one.
+		policy := app.config.cors.policies[corsPolicy(r)]
+		allowed := policy.OriginAllowed(origin)
+
+		// If request has the HTTP method OPTIONS and "Access-Control-Request-Method" header,
+		// then it as a preflight request.
+		if cors.IsPreflight(r) {
+			if !allowed || !policy.PreflightAllowed(r) {
+				app.corsPreflightForbiddenResponse(w, r)
+				return
 			}
+			policy.SetHeaders(w.Header(), origin, true)
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
+
+		// The disallowed origins get no CORS headers, so the browser doesn't
+		// let the scripts read the response.
+		if allowed {
+			policy.SetHeaders(w.Header(), origin, false)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
 
+// expvar panics on publishing the same name twice, so the variables are reused,
+// when the middleware chain is built again (e.g. by the tests).
+func expvarInt(name string) *expvar.Int {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v
+	}
+	return expvar.NewInt(name)
+}
+
+func expvarMap(name string) *expvar.Map {
+	if v, ok := expvar.Get(name).(*expvar.Map); ok {
+		return v
+	}
+	return expvar.NewMap(name)
+}
+
 func (app *application) metrics(next http.Handler) http.Handler {
 	// Init expvar variables when the middleware chain is first built.
-	totalRequestsReceived := expvar.NewInt("total_requests_received")
-	totalResponsesSent := expvar.NewInt("total_responses_sent")
-	totalProcessingTimeMicroseconds := expvar.NewInt("total_processing_time_μs")
+	totalRequestsReceived := expvarInt("total_requests_received")
+	totalResponsesSent := expvarInt("total_responses_sent")
+	totalProcessingTimeMicroseconds := expvarInt("total_processing_time_μs")
 	// Declare a new expvar map to hold the count of responses for each HTTP status code.
-	totalResponsesSentByStatus := expvar.NewMap("total_responses_sent_by_status")
+	totalResponsesSentByStatus := expvarMap("total_responses_sent_by_status")
+
+	// Prometheus metrics, labeled by the route pattern instead of the raw URL.
+	labels := []string{"route", "method", "status"}
+	requests := app.registry.NewCounterVec("http_requests_total", "Number of HTTP requests.", labels...)
+	duration := app.registry.NewHistogramVec("http_request_duration_seconds", "HTTP request latency in seconds.", metrics.DefBuckets, labels...)
+	size := app.registry.NewHistogramVec("http_response_size_bytes", "HTTP response body size in bytes.", []float64{100, 1000, 10000, 100000, 1e6, 1e7}, labels...)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		totalRequestsReceived.Add(1)
+
+		r, state := app.contextRequestState(r)
 		// Returns the metrics struct of handlers.
-		metrics := httpsnoop.CaptureMetrics(next, w, r)
+		m := httpsnoop.CaptureMetrics(next, w, r)
 
 		totalResponsesSent.Add(1)
 		// Get the request processing time in microseconds
 		// Increment the cumulative processing time.
-		totalProcessingTimeMicroseconds.Add(metrics.Duration.Microseconds())
+		totalProcessingTimeMicroseconds.Add(m.Duration.Microseconds())
 		// Increment the count for specific status code by 1.
-		totalResponsesSentByStatus.Add(strconv.Itoa(metrics.Code), 1)
+		totalResponsesSentByStatus.Add(strconv.Itoa(m.Code), 1)
+
+		// The requests answered before the routing (CORS preflights, rate limited,
+		// invalid tokens) and the unknown paths have no route pattern.
+		pattern := state.routePattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		values := []string{pattern, metricsMethod(r.Method), strconv.Itoa(m.Code/100) + "xx"}
+
+		requests.Inc(values...)
+		duration.Observe(m.Duration.Seconds(), values...)
+		size.Observe(float64(m.Written), values...)
 	})
 }
+
+// Returns the method label of the metrics: the unknown methods are grouped,
+// so the clients can't blow up the number of series.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
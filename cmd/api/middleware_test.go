@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/ol-ilyassov/test/internal/ratelimit"
+	"github.com/ol-ilyassov/test/internal/realip"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateFailures(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.config.limiter.policies = map[string]ratelimit.Policy{
+		"default": {Name: "default", Limit: 100, Window: time.Second},
+		"auth":    {Name: "auth", Limit: 3, Window: time.Minute},
+	}
+	store := ratelimit.NewMemoryStore(time.Minute)
+	t.Cleanup(store.StopCleanup)
+	app.limiter = store
+	ts := newTestServer(t, app.routes())
+
+	// The invalid tokens are counted by client IP address, and the client is
+	// rejected before the token lookup once the auth limit is reached.
+	tests := []struct {
+		token      string
+		wantStatus int
+	}{
+		{"garbage", http.StatusUnauthorized},
+		{"XXXXXXXXXXXXXXXXXXXXXXXXXX", http.StatusUnauthorized},
+		{"YYYYYYYYYYYYYYYYYYYYYYYYYY", http.StatusUnauthorized},
+		{"ZZZZZZZZZZZZZZZZZZZZZZZZZZ", http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		status, _ := ts.do(t, http.MethodGet, "/v1/events", tt.token, nil)
+		if status != tt.wantStatus {
+			t.Errorf("token %q: got status %d; want %d", tt.token, status, tt.wantStatus)
+		}
+	}
+
+	// The anonymous requests are limited by their tier, not by the failures.
+	status, _ := ts.do(t, http.MethodGet, "/v1/events", "", nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("anonymous request: got status %d; want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestMetricsAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    []string
+		wantStatus int
+	}{
+		{"loopback", []string{"127.0.0.0/8", "::1"}, http.StatusOK},
+		{"other network", []string{"10.0.0.0/8"}, http.StatusForbidden},
+		{"everyone by default", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			networks, err := realip.ParseNetworks(tt.allowed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.config.metricsAllowed = networks
+
+			// The scrapes aren't rate limited.
+			app.config.limiter.enabled = true
+			app.config.limiter.policies = map[string]ratelimit.Policy{
+				"default": {Name: "default", Limit: 1, Window: time.Hour},
+			}
+			store := ratelimit.NewMemoryStore(time.Minute)
+			t.Cleanup(store.StopCleanup)
+			app.limiter = store
+
+			ts := newTestServer(t, app.routes())
+
+			for _, path := range []string{"/metrics", "/debug/vars", "/metrics"} {
+				rs, err := ts.Client().Get(ts.URL + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rs.Body.Close()
+
+				if rs.StatusCode != tt.wantStatus {
+					t.Errorf("GET %s: got status %d; want %d", path, rs.StatusCode, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"github.com/ol-ilyassov/test/internal/migrate"
+	"github.com/ol-ilyassov/test/migrations"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: api [flags] migrate up [N] | down [N] | status | force VERSION"
+
+// Runs the "migrate" subcommand:
+//
+//	migrate up [N]         apply all (or N) pending migrations
+//	migrate down [N]       roll back the latest (or N latest) migrations
+//	migrate status         print the state of every migration
+//	migrate force VERSION  mark the migrations up to VERSION as applied and clean
+func runMigrate(db *sql.DB, driver string, logger *jsonlog.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	m, err := migrate.New(db, driver, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	// Optional number argument: steps for up/down, version for force.
+	n := 0
+	if len(args) > 1 {
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return errors.New(migrateUsage)
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up(n)
+		for _, mig := range done {
+			logger.PrintInfo("migration applied", map[string]string{
+				"version": strconv.FormatInt(mig.Version, 10),
+				"name":    mig.Name,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			logger.PrintInfo("no pending migrations", nil)
+		}
+		return nil
+
+	case "down":
+		done, err := m.Down(n)
+		for _, mig := range done {
+			logger.PrintInfo("migration rolled back", map[string]string{
+				"version": strconv.FormatInt(mig.Version, 10),
+				"name":    mig.Name,
+			})
+		}
+		return err
+
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		err := m.Force(int64(n))
+		if err != nil {
+			return err
+		}
+		logger.PrintInfo("migration version forced", map[string]string{
+			"version": strconv.Itoa(n),
+		})
+		return nil
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Dirty:
+				state = "dirty"
+			case s.Missing:
+				state = "applied (file missing)"
+			case s.Modified:
+				state = "applied (modified)"
+			case s.Applied:
+				state = "applied"
+			}
+
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return tw.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// Returns an error if there are migrations which haven't been applied yet.
+func checkMigrations(db *sql.DB, driver string) error {
+	m, err := migrate.New(db, driver, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d database migrations are pending, run 'migrate up' first", pending)
+	}
+	return nil
+}
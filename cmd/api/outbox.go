@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"github.com/ol-ilyassov/test/internal/mailer"
+	"strconv"
+	"time"
+)
+
+// Time given to a delivery attempt, before the message is due again.
+const outboxLease = time.Minute
+
+// Number of messages read from the outbox at once.
+const outboxBatchSize = 10
+
+// How often the finished messages older than the retention are deleted.
+const outboxPurgeInterval = time.Hour
+
+// Wakes the dispatcher up, so the emails written by a committed transaction
+// are delivered right away instead of on the next tick.
+func (app *application) wakeOutbox() {
+	select {
+	case app.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// Delivers the due outbox messages on every tick, until stop is closed.
+func (app *application) runOutbox(stop <-chan struct{}) {
+	ticker := time.NewTicker(app.config.outbox.interval)
+	defer ticker.Stop()
+
+	var lastPurge time.Time
+
+	for {
+		app.deliverOutbox(stop)
+
+		if app.config.outbox.retention > 0 && time.Since(lastPurge) >= outboxPurgeInterval {
+			app.purgeOutbox()
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-app.outboxWake:
+		}
+	}
+}
+
+func (app *application) deliverOutbox(stop <-chan struct{}) {
+	for {
+		messages, err := app.models.Outbox.GetDue(outboxBatchSize)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		for _, message := range messages {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			err = app.deliverOutboxMessage(message)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				return
+			}
+		}
+	}
+}
+
+// Deletes the sent and failed messages older than the retention.
+func (app *application) purgeOutbox() {
+	n, err := app.models.Outbox.DeleteFinished(time.Now().Add(-app.config.outbox.retention))
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+	if n > 0 {
+		app.logger.PrintInfo("outbox purged", map[string]string{
+			"deleted": strconv.FormatInt(n, 10),
+		})
+	}
+}
+
+// Sends the message and records the result. Transient failures are retried with
+// exponential backoff, until the maximum number of attempts is reached and the
+// message becomes a dead letter. Permanent failures become dead letters at once.
+func (app *application) deliverOutboxMessage(message *data.OutboxMessage) error {
+	err := app.models.Outbox.Claim(message, outboxLease)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return nil // Claimed by another dispatcher.
+		default:
+			return err
+		}
+	}
+
+	// The logs carry the IDs of the request, which queued the message.
+	ctx := jsonlog.NewContext(context.Background(), map[string]string{
+		"request_id": message.RequestID,
+		"trace_id":   message.TraceID,
+	})
+
+	now := time.Now()
+
+	err = app.mailer.Send(message.Recipient, message.Template, message.Data)
+	switch {
+	case err == nil:
+		message.Status = data.OutboxSent
+		message.LastError = ""
+		message.SentAt = &now
+		app.outboxSent.Add(1)
+		app.logger.PrintInfoContext(ctx, "email sent", map[string]string{
+			"outbox_id": strconv.FormatInt(message.ID, 10),
+			"template":  message.Template,
+			"attempts":  strconv.Itoa(message.Attempts),
+		})
+	case message.Attempts >= app.config.outbox.maxAttempts || mailer.IsPermanent(err):
+		message.Status = data.OutboxFailed
+		message.LastError = err.Error()
+		app.outboxFailed.Add(1)
+		app.logger.PrintErrorContext(ctx, err, map[string]string{
+			"outbox_id": strconv.FormatInt(message.ID, 10),
+			"recipient": message.Recipient,
+			"template":  message.Template,
+			"attempts":  strconv.Itoa(message.Attempts),
+			"status":    data.OutboxFailed,
+		})
+	default:
+		message.LastError = err.Error()
+		message.NextAttemptAt = now.Add(app.outboxBackoff(message.Attempts))
+		app.logger.PrintErrorContext(ctx, err, map[string]string{
+			"outbox_id":       strconv.FormatInt(message.ID, 10),
+			"recipient":       message.Recipient,
+			"template":        message.Template,
+			"attempts":        strconv.Itoa(message.Attempts),
+			"next_attempt_at": message.NextAttemptAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return app.models.Outbox.Update(message)
+}
+
+// Delay before the next attempt: the base backoff doubled after each attempt, up to an hour.
+func (app *application) outboxBackoff(attempts int) time.Duration {
+	backoff := app.config.outbox.backoff
+	for i := 1; i < attempts && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+	if backoff > time.Hour {
+		backoff = time.Hour
+	}
+	return backoff
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/mailer"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+// Fails the given number of deliveries first (with err, or a connection
+// error if it's nil), then delivers.
+type flakyTransport struct {
+	failures  int
+	err       error
+	delivered int
+}
+
+func (t *flakyTransport) Deliver(from string, to []string, msg []byte) error {
+	if t.failures > 0 {
+		t.failures--
+		if t.err != nil {
+			return t.err
+		}
+		return errors.New("connection refused")
+	}
+	t.delivered++
+	return nil
+}
+
+func TestDeliverOutboxMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		maxAttempts  int
+		wantStatus   string
+		wantAttempts int
+	}{
+		{"sent", 0, nil, 3, data.OutboxSent, 1},
+		{"retried", 1, nil, 3, data.OutboxPending, 1},
+		{"retried after a transient reply", 1, &textproto.Error{Code: 451, Msg: "Try again later"}, 3, data.OutboxPending, 1},
+		{"dead letter", 1, nil, 1, data.OutboxFailed, 1},
+		{"dead letter after a permanent reply", 1, &textproto.Error{Code: 550, Msg: "No such user"}, 3, data.OutboxFailed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.outbox.maxAttempts = tt.maxAttempts
+			app.config.outbox.backoff = time.Minute
+
+			transport := &flakyTransport{failures: tt.failures, err: tt.err}
+			app.mailer = mailer.New(transport, "Daryn <no-reply@daryn.kz>")
+
+			r := app.contextSetRequestID(httptest.NewRequest(http.MethodPost, "/v1/tokens/password-reset", nil), "req-1")
+
+			err := app.queueEmail(app.models, r, "alice@example.com", "token_password_reset.tmpl", map[string]interface{}{
+				"passwordResetToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messages, err := app.models.Outbox.GetDue(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("got %d due messages; want 1", len(messages))
+			}
+			message := messages[0]
+			// The dispatcher logs carry the ID of the request.
+			if message.RequestID != "req-1" {
+				t.Errorf("RequestID = %q; want req-1", message.RequestID)
+			}
+
+			err = app.deliverOutboxMessage(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if message.Status != tt.wantStatus {
+				t.Errorf("Status = %q; want %q", message.Status, tt.wantStatus)
+			}
+			if message.Attempts != tt.wantAttempts {
+				t.Errorf("Attempts = %d; want %d", message.Attempts, tt.wantAttempts)
+			}
+
+			// The counters of the finished messages.
+			var wantSent, wantFailed int64
+			switch tt.wantStatus {
+			case data.OutboxSent:
+				wantSent = 1
+			case data.OutboxFailed:
+				wantFailed = 1
+			}
+			if app.outboxSent.Value() != wantSent || app.outboxFailed.Value() != wantFailed {
+				t.Errorf("got %d sent, %d failed; want %d sent, %d failed", app.outboxSent.Value(), app.outboxFailed.Value(), wantSent, wantFailed)
+			}
+
+			// The retried message keeps its data, and isn't due until the backoff.
+			due, err := app.models.Outbox.GetDue(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(due) != 0 {
+				t.Errorf("got %d due messages after the attempt; want 0", len(due))
+			}
+			if tt.wantStatus == data.OutboxPending && message.Data["passwordResetToken"] == nil {
+				t.Error("the data of the pending message is cleared")
+			}
+			// The finished ones lose the token.
+			if tt.wantStatus != data.OutboxPending && len(message.Data) != 0 {
+				t.Errorf("the data of the %s message is kept: %v", message.Status, message.Data)
+			}
+		})
+	}
+}
+
+func TestPurgeOutbox(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.outbox.retention = time.Hour
+	app.mailer = mailer.New(&flakyTransport{}, "Daryn <no-reply@daryn.kz>")
+
+	err := app.queueEmail(app.models, httptest.NewRequest(http.MethodPost, "/", nil), "alice@example.com", "token_password_reset.tmpl", map[string]interface{}{
+		"passwordResetToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.deliverOutbox(nil)
+
+	// Newer than the retention.
+	app.purgeOutbox()
+	counts, err := app.models.Outbox.Counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[data.OutboxSent] != 1 {
+		t.Fatalf("got %d sent messages; want 1", counts[data.OutboxSent])
+	}
+
+	n, err := app.models.Outbox.DeleteFinished(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteFinished() = %d; want 1", n)
+	}
+	// The counter isn't affected by the purge.
+	if app.outboxSent.Value() != 1 {
+		t.Errorf("got %d sent; want 1", app.outboxSent.Value())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/ratelimit"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// Quota tiers of the rate limiter.
+const (
+	tierAnonymous = "anonymous"
+	tierUser      = "user"
+	tierPartner   = "partner"
+)
+
+// All known quota tiers.
+var quotaTiers = []string{tierAnonymous, tierUser, tierPartner}
+
+// Returns the user, which the requests are counted for, and its permissions:
+// the user of the token, or of the session of the HTML UI. It must be used
+// after app.authenticate and app.identifySessionUser.
+func (app *application) quotaUser(r *http.Request) (*data.User, data.Permissions) {
+	if user := app.contextGetUser(r); !user.IsAnonymous() {
+		return user, app.contextGetPermissions(r)
+	}
+	if user, permissions := app.contextGetSessionUser(r); user != nil {
+		return user, permissions
+	}
+	return data.AnonymousUser, nil
+}
+
+// Returns the quota tier of the request: partner for the users with the
+// "quota:partner" permission, user for the other authenticated users.
+func (app *application) quotaTier(r *http.Request) string {
+	user, permissions := app.quotaUser(r)
+	if user.IsAnonymous() {
+		return tierAnonymous
+	}
+
+	if permissions.Include(data.PermissionQuotaPartner) {
+		return tierPartner
+	}
+	return tierUser
+}
+
+// Returns the token bucket policy of the tier. The anonymous clients (and the
+// tiers without a policy of their own) are limited by the "default" policy.
+func (app *application) tierPolicy(tier string) ratelimit.Policy {
+	policy, ok := app.config.limiter.policies[tier]
+	if !ok || tier == tierAnonymous {
+		return app.config.limiter.policies["default"]
+	}
+	return policy
+}
+
+// Returns the key, which the requests of the client are counted under:
+// the API key, the authenticated user or the client IP address.
+func (app *application) quotaKey(r *http.Request) string {
+	if id := app.contextGetAPIKey(r); id != "" {
+		return "key:" + id
+	}
+	if user, _ := app.quotaUser(r); !user.IsAnonymous() {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	return app.contextGetClientIP(r)
+}
+
+// Allowance of a single limit in the GET /v1/me/quota response.
+type allowance struct {
+	Limit      int    `json:"limit"`
+	Remaining  int    `json:"remaining"`
+	Window     string `json:"window"`
+	RetryAfter int    `json:"retry_after,omitempty"` // In seconds, when nothing remains.
+}
+
+func newAllowance(policy ratelimit.Policy, result ratelimit.Result) *allowance {
+	a := &allowance{
+		Limit:     result.Limit,
+		Remaining: result.Remaining,
+		Window:    policy.Window.String(),
+	}
+	if !result.Allowed {
+		a.RetryAfter = int(math.Ceil(result.RetryAfter.Seconds()))
+	}
+	return a
+}
+
+// Reports the quota tier of the client and the remaining allowance, without
+// counting anything on top of this request.
+func (app *application) showQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	tier := app.quotaTier(r)
+
+	var quota struct {
+		Tier    string     `json:"tier"`
+		Enabled bool       `json:"enabled"`
+		Rate    *allowance `json:"rate,omitempty"`
+		Daily   *allowance `json:"daily,omitempty"`
+	}
+	quota.Tier = tier
+	quota.Enabled = app.config.limiter.enabled
+
+	if quota.Enabled {
+		key := app.quotaKey(r)
+
+		policy := app.tierPolicy(tier)
+		result, err := app.limiter.Peek(policy, key)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		quota.Rate = newAllowance(policy, result)
+
+		if policy, ok := app.config.limiter.quotas[tier]; ok {
+			result, err := app.limiter.Peek(policy, key)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			quota.Daily = newAllowance(policy, result)
+		}
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"quota": quota}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/ol-ilyassov/test/internal/data"
+	"net/http"
+	"testing"
+)
+
+func TestShowQuotaTier(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	user, token := ts.registerUser(t, app, "alice@example.com")
+
+	tests := []struct {
+		name     string
+		token    string
+		setup    func()
+		wantTier string
+	}{
+		{"anonymous", "", nil, tierAnonymous},
+		{"user", token, nil, tierUser},
+		{"partner", token, func() {
+			if err := app.models.Permissions.AddForUser(user.ID, data.PermissionQuotaPartner); err != nil {
+				t.Fatal(err)
+			}
+		}, tierPartner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			status, resp := ts.do(t, http.MethodGet, "/v1/me/quota", tt.token, nil)
+			if status != http.StatusOK {
+				t.Fatalf("got status %d; want %d", status, http.StatusOK)
+			}
+			if tier := resp["quota"].(map[string]interface{})["tier"]; tier != tt.wantTier {
+				t.Errorf("got tier %v; want %s", tier, tt.wantTier)
+			}
+		})
+	}
+}
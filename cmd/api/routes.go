@@ -3,28 +3,133 @@ package main
 import (
 	"expvar"
 	"github.com/julienschmidt/httprouter"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/metrics"
 	"net/http"
+	"strings"
 )
 
 func (app *application) routes() http.Handler {
-	router := httprouter.New()
+	router := instrumentedRouter{
+		Router:   httprouter.New(),
+		app:      app,
+		inFlight: app.registry.NewGaugeVec("http_requests_in_flight", "Number of HTTP requests being handled by the route.", "route", "method"),
+	}
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/", app.home)
+	// HTML pages use the session (flash messages, authenticated user),
+	// and their forms are protected against CSRF.
+	dynamic := func(next http.HandlerFunc) http.Handler {
+		return app.session.Enable(app.verifyCSRF(next))
+	}
 
-	router.HandlerFunc(http.MethodGet, "/user", app.user)
+	router.Handler(http.MethodGet, "/", dynamic(app.home))
 
-	//router.HandlerFunc(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+	router.Handler(http.MethodGet, "/user", dynamic(app.user))
+	router.Handler(http.MethodGet, "/user/signup", dynamic(app.signupUserForm))
+	router.Handler(http.MethodPost, "/user/signup", dynamic(app.signupUser))
+	router.Handler(http.MethodGet, "/user/login", dynamic(app.loginUserForm))
+	router.Handler(http.MethodPost, "/user/login", dynamic(app.loginUser))
+	router.Handler(http.MethodPost, "/user/logout", dynamic(app.logoutUser))
+	router.Handler(http.MethodGet, "/search", dynamic(app.requirePagePermission(data.PermissionEventsRead, app.searchPage)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/events", app.requirePermission(data.PermissionEventsRead, app.listEventsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/events", app.requirePermission(data.PermissionEventsWrite, app.createEventHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/events/:id", app.requirePermission(data.PermissionEventsRead, app.showEventHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/events/:id", app.requirePermission(data.PermissionEventsWrite, app.updateEventHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/events/:id", app.requirePermission(data.PermissionEventsWrite, app.deleteEventHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/search", app.requirePermission(data.PermissionEventsRead, app.searchHandler))
 
-	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
+	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.updateUserPasswordHandler)
 
-	//return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
+	router.HandlerFunc(http.MethodGet, "/v1/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/quota", app.showQuotaHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id/permissions", app.requirePermission(data.PermissionUsersAdmin, app.showUserPermissionsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/admin/users/:id/permissions", app.requirePermission(data.PermissionUsersAdmin, app.grantUserPermissionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/permissions", app.requirePermission(data.PermissionUsersAdmin, app.revokeUserPermissionsHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/api-key", app.requireAuthenticationToken(app.createAPIKeyHandler))
+
+	//router.HandlerFunc(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+
+	// Read by the monitoring from the networks of -metrics-allowed.
+	router.Handler(http.MethodGet, "/debug/vars", app.requireMetricsAccess(expvar.Handler()))
+	router.Handler(http.MethodGet, "/metrics", app.requireMetricsAccess(app.registry.Handler()))
+	// The captured emails hold the tokens of all the users.
+	if app.config.env == "development" {
+		router.Handler(http.MethodGet, "/debug/mail", dynamic(app.debugMailPage))
+	}
 
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	//router.Handle(http.MethodGet,"/static/", http.StripPrefix("/static", fileServer))
 	router.Handler(http.MethodGet, "/static/", http.StripPrefix("/static", fileServer))
 
-	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(router))))
+	// The rate limiter runs after the authentication, so the clients (including the users of
+	// the HTML UI) are limited by their quota tier. The failed authentications are limited by
+	// IP address in app.authenticate, before the token lookup.
+	return app.metrics(app.traceRequest(app.accessLog(app.recoverPanic(app.realIP(app.enableCORS(app.authenticate(app.identifySessionUser(app.rateLimit(router)))))))))
+}
+
+// The httprouter, which records the pattern of the matched route for the metrics
+// (httprouter doesn't expose it), and counts the requests in flight per route.
+type instrumentedRouter struct {
+	*httprouter.Router
+	app      *application
+	inFlight *metrics.GaugeVec
+}
+
+func (router instrumentedRouter) Handler(method, pattern string, handler http.Handler) {
+	router.Router.Handler(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.app.contextSetRoutePattern(r, pattern)
+
+		router.inFlight.Add(1, pattern, method)
+		defer router.inFlight.Add(-1, pattern, method)
+
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func (router instrumentedRouter) HandlerFunc(method, pattern string, handler http.HandlerFunc) {
+	router.Handler(method, pattern, handler)
+}
+
+// Returns the name of the rate limiter policy for the request: stricter for
+// the login and the account creation, looser for the static files, and none
+// for the scrapes of the monitoring.
+func rateLimitPolicy(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/static/"):
+		return "static"
+	case r.URL.Path == "/metrics", r.URL.Path == "/debug/vars":
+		return "none"
+	}
+
+	if r.Method == http.MethodPost {
+		switch r.URL.Path {
+		case "/user/login", "/user/signup", "/v1/users", "/v1/tokens/authentication", "/v1/tokens/password-reset":
+			return "auth"
+		}
+	}
+
+	return "default"
+}
+
+// Returns the name of the CORS policy for the request: the static files may be
+// loaded by any origin, the debug pages and the metrics by none.
+func corsPolicy(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/static/"):
+		return "static"
+	case strings.HasPrefix(r.URL.Path, "/debug/"), r.URL.Path == "/metrics":
+		return "none"
+	}
+	return "default"
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/search"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Full-text search over the events titles and descriptions:
+// GET /v1/search?q=...&limit=20
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	query := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 20, v)
+
+	v.Check(query != "", "q", "must be provided")
+	v.Check(len(query) <= 500, "q", "must not be more than 500 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 100, "limit", "must be a maximum of 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results := app.search.Search(query, limit)
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// HTML search page, the query is taken from the "q" parameter.
+func (app *application) searchPage(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	// Cut on a rune boundary, not to split a multi-byte letter.
+	if len(query) > 500 {
+		i := 500
+		for i > 0 && !utf8.RuneStart(query[i]) {
+			i--
+		}
+		query = query[:i]
+	}
+
+	var results []*search.Result
+	if query != "" {
+		results = app.search.Search(query, 50)
+	}
+
+	app.render(w, r, "search.page.tmpl", &templateData{
+		Query:   query,
+		Results: results,
+	})
+}
+
+// Load all events into the search index.
+func (app *application) indexEvents() error {
+	filters := data.Filters{
+		Page:         1,
+		PageSize:     100,
+		Sort:         "event_id",
+		SortSafelist: []string{"event_id"},
+	}
+
+	for {
+		events, metadata, err := app.models.Events.GetAll("", nil, nil, filters)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			app.search.Add(eventDocument(event))
+		}
+		if filters.Page >= metadata.LastPage {
+			return nil
+		}
+		filters.Page++
+	}
+}
+
+func eventDocument(event *data.Events) search.Document {
+	return search.Document{
+		ID:    event.EventId,
+		Title: event.Title,
+		Body:  event.Description,
+	}
+}
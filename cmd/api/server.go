@@ -21,6 +21,14 @@ func (app *application) serve() error {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// Email outbox dispatcher, stopped on shutdown.
+	stopOutbox := make(chan struct{})
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.runOutbox(stopOutbox)
+	}()
+
 	// Graceful Shutdown
 	shutdownError := make(chan error) // Errors from Graceful Shutdown
 
@@ -49,6 +57,7 @@ func (app *application) serve() error {
 		})
 
 		// nil = success, or error (, or 5-second context deadline)
+		close(stopOutbox)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -3,6 +3,8 @@ package main
 import (
 	"github.com/ol-ilyassov/test/internal/data"
 	"github.com/ol-ilyassov/test/internal/forms"
+	"github.com/ol-ilyassov/test/internal/mailer"
+	"github.com/ol-ilyassov/test/internal/search"
 	"html/template"
 	"path/filepath"
 	"time"
@@ -10,19 +12,43 @@ import (
 
 type templateData struct {
 	CurrentYear     int
+	Emails          []mailer.Message
+	EmailsCaptured  bool
 	Flash           string
 	Form            *forms.Form
 	IsAuthenticated bool
+	Query           string
+	Results         []*search.Result
 	User            *data.User
 	UserID          int
+
+	csrfToken func() string
+}
+
+// CSRFToken returns a masked CSRF token of the session: {{csrfField .CSRFToken}}.
+// The session secret is created only when a page renders a form, so the
+// anonymous visitors of the other pages don't get a session.
+func (td *templateData) CSRFToken() string {
+	if td.csrfToken == nil {
+		return ""
+	}
+	return td.csrfToken()
 }
 
 func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// Marks the search highlight as safe HTML. The search package escapes
+// the text itself and adds only <mark> tags.
+func highlighted(s string) template.HTML {
+	return template.HTML(s)
+}
+
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"csrfField":   csrfField,
+	"humanDate":   humanDate,
+	"highlighted": highlighted,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
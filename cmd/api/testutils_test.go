@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"github.com/ol-ilyassov/test/internal/metrics"
+	"github.com/ol-ilyassov/test/internal/realip"
+	"github.com/ol-ilyassov/test/internal/search"
+	"github.com/ol-ilyassov/test/internal/session"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Returns the application with the in-memory models, and without the rate
+// limiter, the mailer and the logs.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache("./../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolver, err := realip.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionStore := session.NewMemoryStore(time.Minute)
+	t.Cleanup(sessionStore.StopCleanup)
+
+	logger := jsonlog.New(ioutil.Discard, jsonlog.LevelOff)
+
+	// Fast password hashing.
+	data.PasswordCost = bcrypt.MinCost
+
+	var cfg config
+	cfg.env = "development"
+
+	return &application{
+		config:        cfg,
+		logger:        logger,
+		accessLogger:  logger,
+		models:        data.NewMemoryModels(),
+		outboxWake:    make(chan struct{}, 1),
+		realip:        resolver,
+		registry:      metrics.NewRegistry(),
+		search:        search.New(),
+		session:       session.New(sessionStore),
+		templateCache: templateCache,
+	}
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return &testServer{ts}
+}
+
+// Sends the request with the JSON body (if it's not nil) and the bearer token
+// (if it's not empty), and returns the status code and the decoded JSON response.
+func (ts *testServer) do(t *testing.T, method, urlPath, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqBody = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	var resp map[string]interface{}
+	err = json.NewDecoder(rs.Body).Decode(&resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, resp
+}
+
+// Registers the user with the given email (and the password "pa55word123"),
+// and returns the user and its authentication token.
+func (ts *testServer) registerUser(t *testing.T, app *application, email string) (*data.User, string) {
+	t.Helper()
+
+	status, _ := ts.do(t, http.MethodPost, "/v1/users", "", map[string]string{"name": "Alice", "email": email, "password": "pa55word123"})
+	if status != http.StatusAccepted {
+		t.Fatalf("register: got status %d", status)
+	}
+
+	status, resp := ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", map[string]string{"email": email, "password": "pa55word123"})
+	if status != http.StatusCreated {
+		t.Fatalf("authenticate: got status %d", status)
+	}
+	token := resp["authentication_token"].(map[string]interface{})["token"].(string)
+
+	user, err := app.models.Users.GetByEmail(data.NormalizeEmail(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, token
+}
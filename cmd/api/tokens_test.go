@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Sends the request authenticated by the API key, and returns the status code.
+func (ts *testServer) doWithAPIKey(t *testing.T, method, urlPath, key string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", key)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	return rs.StatusCode
+}
+
+func TestAPIKeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	user, token := ts.registerUser(t, app, "alice@example.com")
+	user.Activated = true
+	if err := app.models.Users.Update(user); err != nil {
+		t.Fatal(err)
+	}
+
+	status, resp := ts.do(t, http.MethodPost, "/v1/tokens/api-key", token, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create: got status %d; want %d", status, http.StatusCreated)
+	}
+	apiKey := resp["api_key"].(map[string]interface{})
+	key := apiKey["token"].(string)
+
+	// The key authenticates the requests, but doesn't create more keys.
+	if status := ts.doWithAPIKey(t, http.MethodGet, "/v1/me", key); status != http.StatusOK {
+		t.Errorf("GET /v1/me with the API key: got status %d; want %d", status, http.StatusOK)
+	}
+	if status := ts.doWithAPIKey(t, http.MethodPost, "/v1/tokens/api-key", key); status != http.StatusForbidden {
+		t.Errorf("POST /v1/tokens/api-key with the API key: got status %d; want %d", status, http.StatusForbidden)
+	}
+}
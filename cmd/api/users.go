@@ -1,20 +1,274 @@
 package main
 
 import (
+	"errors"
 	"github.com/ol-ilyassov/test/internal/data"
+	"github.com/ol-ilyassov/test/internal/validator"
 	"net/http"
+	"time"
 )
 
+// Shows the authenticated user, anonymous visitors are sent to the login page.
 func (app *application) user(w http.ResponseWriter, r *http.Request) {
+	if !app.isAuthenticated(r) {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
 
-	user := &data.User{
-		Name:    "Alibek",
-		Email:   "a.sovetkazhiyev@gmail.com",
-		Version: 1,
+	// Loaded by app.identifySessionUser.
+	user, _ := app.contextGetSessionUser(r)
+	if user == nil {
+		// The user has been deleted since the login.
+		app.session.Remove(r, "authenticatedUserID")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
 	}
 
 	app.render(w, r, "show.page.tmpl", &templateData{
-		User: user,
-		//UserID:  app.session.GetInt(r, "authenticatedUserID"),
+		User:   user,
+		UserID: int(user.ID),
 	})
 }
+
+func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Expected data from the request body.
+	var input struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &data.User{
+		Name:      input.Name,
+		Email:     data.NormalizeEmail(input.Email),
+		Activated: false,
+	}
+
+	// Generate and store the hashed and plaintext passwords.
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.createUser(r, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Inserts the user with the default permissions, and queues the welcome email
+// with an activation token. Everything is written in one transaction, so the
+// email is sent only for a created user. Returns data.ErrDuplicateEmail, if the
+// email address is taken. The email is logged with the IDs of the request r.
+func (app *application) createUser(r *http.Request, user *data.User) error {
+	err := app.models.WithTx(func(tx data.Models) error {
+		err := tx.Users.Insert(user)
+		if err != nil {
+			return err
+		}
+
+		// Grant the default permissions to the new user.
+		err = tx.Permissions.AddForUser(user.ID, data.DefaultPermissions...)
+		if err != nil {
+			return err
+		}
+
+		// Generate a new activation token with a 3-day expiry time.
+		token, err := tx.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+		if err != nil {
+			return err
+		}
+
+		return app.queueEmail(tx, r, user.Email, "user_welcome.tmpl", map[string]interface{}{
+			"activationToken": token.Plaintext,
+			"userID":          user.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	app.wakeOutbox()
+	return nil
+}
+
+// Returns the user with the email and password. Returns data.ErrRecordNotFound,
+// if there is no such user or the password doesn't match. The password hash is
+// upgraded, if it was created with a lower cost.
+func (app *application) authenticateUser(email, password string) (*data.User, error) {
+	user, err := app.models.Users.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := user.Password.Matches(password)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, data.ErrRecordNotFound
+	}
+
+	err = app.models.Users.UpgradePassword(user, password)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Retrieve the details of the user associated with the token.
+	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired activation token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.Activated = true
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Delete all activation tokens for the user.
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Delete all password reset tokens for the user.
+	err = app.models.Tokens.DeleteAllForUser(data.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"message": "your password was successfully reset"}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// Returns the details of the authenticated user.
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/ol-ilyassov/test/internal/data"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	valid := map[string]string{"name": "Alice", "email": "alice@example.com", "password": "pa55word123"}
+
+	tests := []struct {
+		name       string
+		body       map[string]string
+		wantStatus int
+	}{
+		{"valid", valid, http.StatusAccepted},
+		{"duplicate email", valid, http.StatusUnprocessableEntity},
+		{"duplicate email in another case", map[string]string{"name": "Alice", "email": "Alice@Example.com", "password": "pa55word123"}, http.StatusUnprocessableEntity},
+		{"invalid email", map[string]string{"name": "Bob", "email": "bob", "password": "pa55word123"}, http.StatusUnprocessableEntity},
+		{"short password", map[string]string{"name": "Bob", "email": "bob@example.com", "password": "pa55"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _ := ts.do(t, http.MethodPost, "/v1/users", "", tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("got status %d; want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	// The welcome email with the activation token is queued.
+	counts, err := app.models.Outbox.Counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[data.OutboxPending] != 1 {
+		t.Errorf("got %d pending emails; want 1", counts[data.OutboxPending])
+	}
+}
+
+func TestUserEmailCase(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// The email is stored in lowercase, and matched in any case.
+	user, _ := ts.registerUser(t, app, "Alice@Example.com")
+	if user.Email != "alice@example.com" {
+		t.Errorf("got email %q; want %q", user.Email, "alice@example.com")
+	}
+
+	status, _ := ts.do(t, http.MethodPost, "/v1/tokens/authentication", "", map[string]string{"email": "ALICE@example.com", "password": "pa55word123"})
+	if status != http.StatusCreated {
+		t.Errorf("authenticate: got status %d; want %d", status, http.StatusCreated)
+	}
+}
+
+func TestListEventsPermissions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	user, token := ts.registerUser(t, app, "alice@example.com")
+
+	tests := []struct {
+		name       string
+		token      string
+		setup      func()
+		wantStatus int
+	}{
+		{"anonymous", "", nil, http.StatusUnauthorized},
+		{"invalid token", "XXXXXXXXXXXXXXXXXXXXXXXXXX", nil, http.StatusUnauthorized},
+		{"not activated", token, nil, http.StatusForbidden},
+		{"without the permission", token, func() {
+			user.Activated = true
+			if err := app.models.Users.Update(user); err != nil {
+				t.Fatal(err)
+			}
+			if err := app.models.Permissions.RemoveForUser(user.ID, data.PermissionEventsRead); err != nil {
+				t.Fatal(err)
+			}
+		}, http.StatusForbidden},
+		{"with the permission", token, func() {
+			if err := app.models.Permissions.AddForUser(user.ID, data.PermissionEventsRead); err != nil {
+				t.Fatal(err)
+			}
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			status, _ := ts.do(t, http.MethodGet, "/v1/events", tt.token, nil)
+			if status != tt.wantStatus {
+				t.Errorf("got status %d; want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -4,6 +4,10 @@ go 1.16
 
 require (
 	github.com/felixge/httpsnoop v1.0.2
+	github.com/glebarez/go-sqlite v1.20.3
+	github.com/go-sql-driver/mysql v1.6.0
 	github.com/julienschmidt/httprouter v1.3.0
-	golang.org/x/time v0.0.0-20210611083556-38a9dc6acbc6
+	github.com/kljensen/snowball v0.6.0
+	github.com/lib/pq v1.10.7
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
@@ -0,0 +1,158 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy of the cross-origin requests. The zero value allows no cross-origin
+// requests at all.
+type Policy struct {
+	// Exact origins ("https://daryn.kz"), wildcard subdomain patterns
+	// ("https://*.daryn.kz") or "*" for any origin.
+	AllowedOrigins []string
+	// Methods and request headers allowed by the preflight. The simple methods
+	// (GET, HEAD, POST) are always allowed.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// Response headers, which the scripts may read.
+	ExposedHeaders []string
+	// Allow the cookies and the Authorization header. The origin is then always
+	// echoed back, never "*".
+	AllowCredentials bool
+	// How long the browsers may cache the preflight response.
+	MaxAge time.Duration
+}
+
+// ValidateOrigins checks the origins and patterns of AllowedOrigins.
+func ValidateOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+
+		i := strings.Index(origin, "://")
+		if i < 1 || strings.HasSuffix(origin, "/") {
+			return fmt.Errorf("invalid CORS origin %q, expected scheme://host[:port]", origin)
+		}
+
+		host := origin[i+3:]
+		switch {
+		case host == "":
+			return fmt.Errorf("invalid CORS origin %q, expected scheme://host[:port]", origin)
+		case strings.Count(origin, "*") > 1:
+			return fmt.Errorf("invalid CORS origin %q, only one wildcard is allowed", origin)
+		case strings.Contains(origin, "*") && !strings.HasPrefix(host, "*."):
+			return fmt.Errorf("invalid CORS origin %q, the wildcard must be the leftmost label, e.g. https://*.daryn.kz", origin)
+		}
+	}
+	return nil
+}
+
+// OriginAllowed reports whether the policy allows the origin.
+func (p *Policy) OriginAllowed(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// PreflightAllowed reports whether the policy allows the method and the headers
+// requested by the preflight (Access-Control-Request-Method and -Headers).
+func (p *Policy) PreflightAllowed(r *http.Request) bool {
+	method := r.Header.Get("Access-Control-Request-Method")
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		if !contains(p.AllowedMethods, method) {
+			return false
+		}
+	}
+
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !contains(p.AllowedHeaders, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetHeaders sets the CORS headers of the response to the allowed origin.
+// The preflight responses get the allowed methods, headers and max age too.
+func (p *Policy) SetHeaders(h http.Header, origin string, preflight bool) {
+	if contains(p.AllowedOrigins, "*") && !p.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(p.ExposedHeaders) != 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+		}
+		return
+	}
+
+	if len(p.AllowedMethods) != 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(p.AllowedMethods, ", "))
+	}
+	if len(p.AllowedHeaders) != 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+	}
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+}
+
+// IsPreflight reports whether the request is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Matches the origin against the allowed origin or pattern. The wildcard of
+// "https://*.daryn.kz" stands for one or more subdomain labels, so neither
+// "https://daryn.kz" nor "https://evil-daryn.kz" match.
+func matchOrigin(allowed, origin string) bool {
+	if allowed == "*" {
+		return true
+	}
+
+	i := strings.Index(allowed, "*")
+	if i == -1 {
+		return strings.EqualFold(allowed, origin)
+	}
+
+	prefix, suffix := allowed[:i], allowed[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) ||
+		!strings.EqualFold(origin[:len(prefix)], prefix) ||
+		!strings.EqualFold(origin[len(origin)-len(suffix):], suffix) {
+		return false
+	}
+
+	// The subdomain labels must not smuggle a path, port or credentials in.
+	for _, c := range origin[len(prefix) : len(origin)-len(suffix)] {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Case-insensitive, like the header names.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		allowed string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://example.com", true},
+		{"https://daryn.kz", "https://daryn.kz", true},
+		{"https://daryn.kz", "HTTPS://Daryn.KZ", true},
+		{"https://daryn.kz", "http://daryn.kz", false},
+		{"https://daryn.kz", "https://daryn.kz:8443", false},
+		{"https://*.daryn.kz", "https://app.daryn.kz", true},
+		{"https://*.daryn.kz", "https://a.b.daryn.kz", true},
+		{"https://*.daryn.kz", "https://daryn.kz", false},
+		{"https://*.daryn.kz", "https://evil-daryn.kz", false},
+		{"https://*.daryn.kz", "https://evil.com/.daryn.kz", false},
+		{"https://*.daryn.kz", "https://user@x.daryn.kz", false},
+		{"https://*.daryn.kz", "https://x:1.daryn.kz", false},
+		{"https://*.daryn.kz", "http://app.daryn.kz", false},
+		{"https://*.daryn.kz:8443", "https://app.daryn.kz:8443", true},
+		{"https://*.daryn.kz:8443", "https://app.daryn.kz", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchOrigin(tt.allowed, tt.origin); got != tt.want {
+			t.Errorf("matchOrigin(%q, %q) = %v; want %v", tt.allowed, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestValidateOrigins(t *testing.T) {
+	tests := []struct {
+		origin  string
+		wantErr bool
+	}{
+		{"*", false},
+		{"https://daryn.kz", false},
+		{"http://localhost:3000", false},
+		{"https://*.daryn.kz", false},
+		{"daryn.kz", true},
+		{"https://", true},
+		{"https://daryn.kz/", true},
+		{"https://*.*.daryn.kz", true},
+		{"https://app.*.daryn.kz", true},
+		{"https://*daryn.kz", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateOrigins([]string{tt.origin})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateOrigins(%q) error = %v; want error %v", tt.origin, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPreflightAllowed(t *testing.T) {
+	p := &Policy{
+		AllowedMethods: []string{http.MethodPut, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		headers string
+		want    bool
+	}{
+		{"simple method", http.MethodPost, "", true},
+		{"allowed method", http.MethodDelete, "", true},
+		{"not allowed method", http.MethodPatch, "", false},
+		{"allowed headers", http.MethodPut, "authorization, content-type", true},
+		{"not allowed header", http.MethodGet, "Authorization, X-Debug", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, "/", nil)
+			r.Header.Set("Origin", "https://daryn.kz")
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+
+			if !IsPreflight(r) {
+				t.Fatal("IsPreflight() = false")
+			}
+			if got := p.PreflightAllowed(r); got != tt.want {
+				t.Errorf("PreflightAllowed() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		preflight bool
+		want      map[string]string
+	}{
+		{
+			name:   "any origin",
+			policy: Policy{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Request-ID"}},
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+				"Access-Control-Max-Age":        "",
+			},
+		},
+		{
+			name:   "any origin with credentials",
+			policy: Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			want: map[string]string{
+				"Access-Control-Allow-Origin":      "https://daryn.kz",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name: "preflight",
+			policy: Policy{
+				AllowedOrigins: []string{"https://daryn.kz"},
+				AllowedMethods: []string{http.MethodPut, http.MethodDelete},
+				AllowedHeaders: []string{"Authorization"},
+				ExposedHeaders: []string{"X-Request-ID"},
+				MaxAge:         10 * time.Minute,
+			},
+			preflight: true,
+			want: map[string]string{
+				"Access-Control-Allow-Origin":   "https://daryn.kz",
+				"Access-Control-Allow-Methods":  "PUT, DELETE",
+				"Access-Control-Allow-Headers":  "Authorization",
+				"Access-Control-Max-Age":        "600",
+				"Access-Control-Expose-Headers": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			tt.policy.SetHeaders(h, "https://daryn.kz", tt.preflight)
+
+			for name, want := range tt.want {
+				if got := h.Get(name); got != want {
+					t.Errorf("%s = %q; want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
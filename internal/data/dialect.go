@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/sqldialect"
+	"strings"
+)
+
+// Dialect identifies the SQL database, which the models work with.
+// Queries are written once with "?" placeholders and adapted by the dialect.
+type Dialect string
+
+const (
+	DialectMySQL    Dialect = "mysql"
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// Returns the dialect with the given name.
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(name); d {
+	case DialectMySQL, DialectPostgres, DialectSQLite:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unsupported database driver %q", name)
+	}
+}
+
+// Rewrites "?" placeholders to the dialect's bind variables ("$1", "$2", ... for PostgreSQL).
+func (d Dialect) rebind(query string) string {
+	return sqldialect.Rebind(string(d), query)
+}
+
+// Executes the INSERT query and returns the generated value of the idColumn.
+// PostgreSQL doesn't support LastInsertId(), so the RETURNING clause is used there.
+func (d Dialect) insert(ctx context.Context, db DBTX, query, idColumn string, args ...interface{}) (int64, error) {
+	var id int64
+
+	if d == DialectPostgres {
+		err := db.QueryRowContext(ctx, d.rebind(query)+" RETURNING "+idColumn, args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// Reports whether the error is a violation of the unique index on the column.
+//   - MySQL: Error 1062: Duplicate entry 'a@b.c' for key 'users.email'
+//   - PostgreSQL: pq: duplicate key value violates unique constraint "users_email_key"
+//   - SQLite: UNIQUE constraint failed: users.email
+func (d Dialect) isUniqueViolation(err error, column string) bool {
+	msg := err.Error()
+
+	switch d {
+	case DialectPostgres:
+		return strings.Contains(msg, "duplicate key value") && strings.Contains(msg, column)
+	case DialectSQLite:
+		return strings.Contains(msg, "UNIQUE constraint failed") && strings.Contains(msg, column)
+	default:
+		return strings.Contains(msg, "Duplicate entry") && strings.Contains(msg, column)
+	}
+}
@@ -1,7 +1,12 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"strings"
 	"time"
 )
 
@@ -12,8 +17,226 @@ type Events struct {
 	IconId       int64      `json:"icon_id"`
 	ContactsLink int64      `json:"contacts_link"`
 	CreatedTime  *time.Time `json:"created_time"`
+	Version      int32      `json:"version"` // Starts at 1, incremented on each update.
+}
+
+func ValidateEvent(v *validator.Validator, event *Events) {
+	v.Check(event.Title != "", "title", "must be provided")
+	v.Check(len(event.Title) <= 500, "title", "must not be more than 500 bytes long")
+
+	v.Check(event.Description != "", "description", "must be provided")
+	v.Check(len(event.Description) <= 10000, "description", "must not be more than 10000 bytes long")
+
+	v.Check(event.IconId > 0, "icon_id", "must be a positive integer")
+	v.Check(event.ContactsLink > 0, "contacts_link", "must be a positive integer")
 }
 
 type EventModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Dialect Dialect
+}
+
+// Insert a new record in the events table.
+// EventId, CreatedTime and Version fields are filled in after the insert.
+func (m EventModel) Insert(event *Events) error {
+	query := `
+		INSERT INTO events (title, description, icon_id, contacts_link, created_time, version)
+		VALUES (?, ?, ?, ?, ?, 1)`
+
+	createdTime := time.Now().UTC().Truncate(time.Second)
+	args := []interface{}{event.Title, event.Description, event.IconId, event.ContactsLink, createdTime}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id, err := m.Dialect.insert(ctx, m.DB, query, "event_id", args...)
+	if err != nil {
+		return err
+	}
+
+	event.EventId = id
+	event.CreatedTime = &createdTime
+	event.Version = 1
+	return nil
+}
+
+// Retrieve a specific record from the events table.
+func (m EventModel) Get(id int64) (*Events, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT event_id, title, description, icon_id, contacts_link, created_time, version
+		FROM events
+		WHERE event_id = ?`
+
+	var event Events
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(query), id).Scan(
+		&event.EventId,
+		&event.Title,
+		&event.Description,
+		&event.IconId,
+		&event.ContactsLink,
+		&event.CreatedTime,
+		&event.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &event, nil
+}
+
+// Escapes the LIKE wildcards with "!" (see ESCAPE in GetAll).
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Returns a page of records from the events table, which match the filters:
+// a title substring (case-insensitive) and a creation time range, which
+// includes createdFrom and excludes createdBefore. Zero values (empty title,
+// nil times) disable the corresponding filter.
+func (m EventModel) GetAll(title string, createdFrom, createdBefore *time.Time, filters Filters) ([]*Events, Metadata, error) {
+	var conditions []string
+	var args []interface{}
+
+	if title != "" {
+		// The wildcards of the title are matched literally. The escape character
+		// isn't a backslash, which MySQL string literals treat specially.
+		conditions = append(conditions, "LOWER(title) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(title))+"%")
+	}
+	if createdFrom != nil {
+		conditions = append(conditions, "created_time >= ?")
+		args = append(args, createdFrom.UTC())
+	}
+	if createdBefore != nil {
+		conditions = append(conditions, "created_time < ?")
+		args = append(args, createdBefore.UTC())
+	}
+
+	where := ""
+	if len(conditions) != 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// The window function counts all matching records, regardless of LIMIT and OFFSET.
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) OVER(), event_id, title, description, icon_id, contacts_link, created_time, version
+		FROM events
+		%s
+		ORDER BY %s %s, event_id ASC
+		LIMIT ? OFFSET ?`, where, filters.sortColumn(), filters.sortDirection())
+
+	args = append(args, filters.limit(), filters.offset())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(query), args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*Events{}
+
+	for rows.Next() {
+		var event Events
+
+		err := rows.Scan(
+			&totalRecords,
+			&event.EventId,
+			&event.Title,
+			&event.Description,
+			&event.IconId,
+			&event.ContactsLink,
+			&event.CreatedTime,
+			&event.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+// Update a specific record in the events table.
+// Returns ErrEditConflict if the version has been changed since the record was read.
+func (m EventModel) Update(event *Events) error {
+	query := `
+		UPDATE events
+		SET title = ?, description = ?, icon_id = ?, contacts_link = ?, version = version + 1
+		WHERE event_id = ? AND version = ?`
+
+	args := []interface{}{
+		event.Title,
+		event.Description,
+		event.IconId,
+		event.ContactsLink,
+		event.EventId,
+		event.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	event.Version++
+	return nil
+}
+
+// Delete a specific record from the events table.
+func (m EventModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM events
+		WHERE event_id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		event := &Events{Title: "Наурыз мейрамы", Description: "Description", IconId: 1, ContactsLink: 1}
+		if err := models.Events.Insert(event); err != nil {
+			t.Fatal(err)
+		}
+		if event.EventId == 0 || event.Version != 1 || event.CreatedTime == nil {
+			t.Fatalf("got ID %d, version %d, created time %v; want them filled in", event.EventId, event.Version, event.CreatedTime)
+		}
+
+		stale := *event
+
+		tests := []struct {
+			name    string
+			run     func() error
+			wantErr error
+		}{
+			{"get", func() error {
+				got, err := models.Events.Get(event.EventId)
+				if err == nil && got.Title != event.Title {
+					t.Errorf("got title %q; want %q", got.Title, event.Title)
+				}
+				return err
+			}, nil},
+			{"get a missing ID", func() error {
+				_, err := models.Events.Get(999)
+				return err
+			}, ErrRecordNotFound},
+			{"update", func() error {
+				event.Title = "Қазақ тілі күні"
+				err := models.Events.Update(event)
+				if err == nil && event.Version != 2 {
+					t.Errorf("got version %d; want 2", event.Version)
+				}
+				return err
+			}, nil},
+			{"update a stale version", func() error {
+				return models.Events.Update(&stale)
+			}, ErrEditConflict},
+			{"delete", func() error {
+				return models.Events.Delete(event.EventId)
+			}, nil},
+			{"update a deleted event", func() error {
+				return models.Events.Update(event)
+			}, ErrEditConflict},
+			{"delete a missing ID", func() error {
+				return models.Events.Delete(event.EventId)
+			}, ErrRecordNotFound},
+		}
+
+		for _, tt := range tests {
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got error %v; want %v", tt.name, err, tt.wantErr)
+			}
+		}
+	})
+}
+
+func TestEventsGetAllTitle(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		for _, title := range []string{"Наурыз мейрамы", "Қазақ тілі күні", "Open 100% day"} {
+			event := &Events{Title: title, Description: "Description", IconId: 1, ContactsLink: 1}
+			if err := models.Events.Insert(event); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		tests := []struct {
+			title string
+			want  []string
+		}{
+			{"наурыз", []string{"Наурыз мейрамы"}},
+			{"ҚАЗАҚ", []string{"Қазақ тілі күні"}},
+			{"OPEN", []string{"Open 100% day"}},
+			{"100%", []string{"Open 100% day"}},
+			{"0_%", nil},
+			{"", []string{"Наурыз мейрамы", "Қазақ тілі күні", "Open 100% day"}},
+		}
+
+		for _, tt := range tests {
+			filters := Filters{Page: 1, PageSize: 20, Sort: "event_id", SortSafelist: []string{"event_id"}}
+
+			events, _, err := models.Events.GetAll(tt.title, nil, nil, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, event := range events {
+				got = append(got, event.Title)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("title %q: got %q; want %q", tt.title, got, tt.want)
+			}
+		}
+	})
+}
@@ -0,0 +1,570 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore holds the data of all in-memory models behind a single mutex,
+// so the relations between users, tokens and permissions stay consistent.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	users       map[int64]User
+	lastUserID  int64
+	tokens      map[string]Token // Keyed by the token hash.
+	permissions map[int64]map[string]bool
+	events      map[int64]Events
+	lastEventID int64
+	outbox      map[int64]OutboxMessage
+	lastMailID  int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:       make(map[int64]User),
+		tokens:      make(map[string]Token),
+		permissions: make(map[int64]map[string]bool),
+		events:      make(map[int64]Events),
+		outbox:      make(map[int64]OutboxMessage),
+	}
+}
+
+// Runs fn as a transaction on a copy of the data, which replaces the data if
+// fn succeeds. The write lock is held until then, so the other models wait for
+// the transaction, and a rollback can't undo their changes.
+func (s *memoryStore) withTx(fn func(tx *memoryStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := s.clone()
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	s.restore(tx)
+	return nil
+}
+
+// Returns a copy of the data. The caller must hold the read lock.
+func (s *memoryStore) clone() *memoryStore {
+	c := newMemoryStore()
+
+	for id, user := range s.users {
+		c.users[id] = user
+	}
+	for hash, token := range s.tokens {
+		c.tokens[hash] = token
+	}
+	for id, codes := range s.permissions {
+		c.permissions[id] = make(map[string]bool, len(codes))
+		for code := range codes {
+			c.permissions[id][code] = true
+		}
+	}
+	for id, event := range s.events {
+		c.events[id] = event
+	}
+	for id, message := range s.outbox {
+		c.outbox[id] = message
+	}
+
+	c.lastUserID = s.lastUserID
+	c.lastEventID = s.lastEventID
+	c.lastMailID = s.lastMailID
+	return c
+}
+
+// Replaces the data with the copy. The caller must hold the write lock.
+func (s *memoryStore) restore(snapshot *memoryStore) {
+	s.users = snapshot.users
+	s.lastUserID = snapshot.lastUserID
+	s.tokens = snapshot.tokens
+	s.permissions = snapshot.permissions
+	s.events = snapshot.events
+	s.lastEventID = snapshot.lastEventID
+	s.outbox = snapshot.outbox
+	s.lastMailID = snapshot.lastMailID
+}
+
+// Users.
+
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+func (m MemoryUserModel) Insert(user *User) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	s.lastUserID++
+	user.ID = s.lastUserID
+	user.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	user.Version = 1
+
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (m MemoryUserModel) GetByID(id int64) (*User, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (m MemoryUserModel) GetByEmail(email string) (*User, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.tokens[string(tokenHash[:])]
+	if !ok || token.Scope != tokenScope || !token.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+
+	user, ok := s.users[token.UserID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (m MemoryUserModel) Update(user *User) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.users[user.ID]
+	if !ok || current.Version != user.Version {
+		return ErrEditConflict
+	}
+	if s.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+
+	user.Version++
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (m MemoryUserModel) UpgradePassword(user *User, plaintextPassword string) error {
+	return upgradePassword(m.Update, user, plaintextPassword)
+}
+
+// Deletes the user together with the tokens and permissions.
+func (m MemoryUserModel) Delete(id int64) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(s.users, id)
+	delete(s.permissions, id)
+	for hash, token := range s.tokens {
+		if token.UserID == id {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// Reports whether another user (with a different ID) already has the email.
+// The emails are compared exactly, the handlers normalize them.
+func (s *memoryStore) emailTaken(email string, id int64) bool {
+	for _, user := range s.users {
+		if user.Email == email && user.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// Tokens.
+
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (m MemoryTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m MemoryTokenModel) Insert(token *Token) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[string(token.Hash)] = *token
+	return nil
+}
+
+func (m MemoryTokenModel) DeleteAllForUser(scope string, userID int64) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, token := range s.tokens {
+		if token.Scope == scope && token.UserID == userID {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// Permissions.
+
+type MemoryPermissionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var permissions Permissions
+	for code := range s.permissions[userID] {
+		permissions = append(permissions, code)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+// Grants the known permission codes to an existing user, like the SQL model does.
+func (m MemoryPermissionModel) AddForUser(userID int64, codes ...string) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[userID]; !ok {
+		return nil
+	}
+
+	for _, code := range codes {
+		if !Permissions(PermissionCodes).Include(code) {
+			continue
+		}
+		if s.permissions[userID] == nil {
+			s.permissions[userID] = make(map[string]bool)
+		}
+		s.permissions[userID][code] = true
+	}
+	return nil
+}
+
+func (m MemoryPermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range codes {
+		delete(s.permissions[userID], code)
+	}
+	return nil
+}
+
+// Events.
+
+type MemoryEventModel struct {
+	store *memoryStore
+}
+
+func (m MemoryEventModel) Insert(event *Events) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdTime := time.Now().UTC().Truncate(time.Second)
+
+	s.lastEventID++
+	event.EventId = s.lastEventID
+	event.CreatedTime = &createdTime
+	event.Version = 1
+
+	s.events[event.EventId] = *event
+	return nil
+}
+
+func (m MemoryEventModel) Get(id int64) (*Events, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &event, nil
+}
+
+// Same filtering, sorting and pagination as EventModel.GetAll.
+func (m MemoryEventModel) GetAll(title string, createdFrom, createdBefore *time.Time, filters Filters) ([]*Events, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	s := m.store
+	s.mu.RLock()
+
+	var matched []*Events
+	for _, event := range s.events {
+		if title != "" && !strings.Contains(strings.ToLower(event.Title), strings.ToLower(title)) {
+			continue
+		}
+		if createdFrom != nil && event.CreatedTime.Before(*createdFrom) {
+			continue
+		}
+		if createdBefore != nil && !event.CreatedTime.Before(*createdBefore) {
+			continue
+		}
+		event := event
+		matched = append(matched, &event)
+	}
+
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if direction == "DESC" {
+			a, b = b, a
+		}
+		switch column {
+		case "title":
+			if a.Title != b.Title {
+				return a.Title < b.Title
+			}
+		case "created_time":
+			if !a.CreatedTime.Equal(*b.CreatedTime) {
+				return a.CreatedTime.Before(*b.CreatedTime)
+			}
+		default:
+			if a.EventId != b.EventId {
+				return a.EventId < b.EventId
+			}
+		}
+		// Secondary sort by event_id ascending, regardless of the direction.
+		return matched[i].EventId < matched[j].EventId
+	})
+
+	events := []*Events{}
+	if offset := filters.offset(); offset < len(matched) {
+		end := offset + filters.limit()
+		if end > len(matched) {
+			end = len(matched)
+		}
+		events = append(events, matched[offset:end]...)
+	}
+
+	// Like the SQL window function, the total is unknown for a page past the end.
+	if len(events) == 0 {
+		return events, Metadata{}, nil
+	}
+
+	metadata := calculateMetadata(len(matched), filters.Page, filters.PageSize)
+
+	return events, metadata, nil
+}
+
+func (m MemoryEventModel) Update(event *Events) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.events[event.EventId]
+	if !ok || current.Version != event.Version {
+		return ErrEditConflict
+	}
+
+	event.Version++
+	s.events[event.EventId] = *event
+	return nil
+}
+
+func (m MemoryEventModel) Delete(id int64) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(s.events, id)
+	return nil
+}
+
+// Outbox.
+
+type MemoryOutboxModel struct {
+	store *memoryStore
+}
+
+func (m MemoryOutboxModel) Insert(message *OutboxMessage) error {
+	// Round trip through JSON, so the data is the same as the SQL model returns.
+	b, err := json.Marshal(message.Data)
+	if err != nil {
+		return err
+	}
+	data, err := decodeOutboxData(string(b))
+	if err != nil {
+		return err
+	}
+
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s.lastMailID++
+	message.ID = s.lastMailID
+	message.Status = OutboxPending
+	message.Attempts = 0
+	message.LastError = ""
+	message.NextAttemptAt = now
+	message.CreatedAt = now
+
+	stored := *message
+	stored.Data = data
+	s.outbox[message.ID] = stored
+	return nil
+}
+
+func (m MemoryOutboxModel) GetDue(limit int) ([]*OutboxMessage, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UTC()
+
+	messages := []*OutboxMessage{}
+	for _, message := range s.outbox {
+		if message.Status == OutboxPending && !message.NextAttemptAt.After(now) {
+			message := message
+			messages = append(messages, &message)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].NextAttemptAt.Equal(messages[j].NextAttemptAt) {
+			return messages[i].NextAttemptAt.Before(messages[j].NextAttemptAt)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (m MemoryOutboxModel) Claim(message *OutboxMessage, lease time.Duration) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.outbox[message.ID]
+	if !ok || current.Attempts != message.Attempts || current.Status != OutboxPending {
+		return ErrEditConflict
+	}
+
+	current.Attempts++
+	current.NextAttemptAt = time.Now().UTC().Add(lease).Truncate(time.Second)
+	s.outbox[message.ID] = current
+
+	message.Attempts = current.Attempts
+	message.NextAttemptAt = current.NextAttemptAt
+	return nil
+}
+
+func (m MemoryOutboxModel) Update(message *OutboxMessage) error {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.outbox[message.ID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	current.Status = message.Status
+	current.Attempts = message.Attempts
+	current.LastError = message.LastError
+	current.NextAttemptAt = message.NextAttemptAt.UTC().Truncate(time.Second)
+	current.SentAt = nil
+	if message.SentAt != nil {
+		t := message.SentAt.UTC().Truncate(time.Second)
+		current.SentAt = &t
+	}
+	if current.Status != OutboxPending {
+		current.Data = map[string]interface{}{}
+		message.Data = map[string]interface{}{}
+	}
+	s.outbox[message.ID] = current
+	return nil
+}
+
+func (m MemoryOutboxModel) DeleteFinished(before time.Time) (int64, error) {
+	s := m.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for id, message := range s.outbox {
+		if message.Status != OutboxPending && message.CreatedAt.Before(before) {
+			delete(s.outbox, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m MemoryOutboxModel) Counts() (map[string]int, error) {
+	s := m.store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{OutboxPending: 0, OutboxSent: 0, OutboxFailed: 0}
+	for _, message := range s.outbox {
+		counts[message.Status]++
+	}
+	return counts, nil
+}
@@ -1,7 +1,10 @@
 package data
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -9,18 +12,121 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// DBTX is implemented by both *sql.DB and *sql.Tx, so the SQL models
+// work the same way inside and outside of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Repositories implemented by both the SQL models and the in-memory ones.
+
+type EventRepository interface {
+	Insert(event *Events) error
+	Get(id int64) (*Events, error)
+	GetAll(title string, createdFrom, createdBefore *time.Time, filters Filters) ([]*Events, Metadata, error)
+	Update(event *Events) error
+	Delete(id int64) error
+}
+
+type OutboxRepository interface {
+	Insert(message *OutboxMessage) error
+	GetDue(limit int) ([]*OutboxMessage, error)
+	Claim(message *OutboxMessage, lease time.Duration) error
+	Update(message *OutboxMessage) error
+	DeleteFinished(before time.Time) (int64, error)
+	Counts() (map[string]int, error)
+}
+
+type PermissionRepository interface {
+	GetAllForUser(userID int64) (Permissions, error)
+	AddForUser(userID int64, codes ...string) error
+	RemoveForUser(userID int64, codes ...string) error
+}
+
+type TokenRepository interface {
+	New(userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(token *Token) error
+	DeleteAllForUser(scope string, userID int64) error
+}
+
+type UserRepository interface {
+	Insert(user *User) error
+	GetByID(id int64) (*User, error)
+	GetByEmail(email string) (*User, error)
+	GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+	Update(user *User) error
+	UpgradePassword(user *User, plaintextPassword string) error
+	Delete(id int64) error
+}
+
 type Models struct {
-	Users UserModel
+	Events      EventRepository
+	Outbox      OutboxRepository
+	Permissions PermissionRepository
+	Tokens      TokenRepository
+	Users       UserRepository
+
+	// Used by WithTx.
+	db      *sql.DB
+	dialect Dialect
+	store   *memoryStore
 }
 
-func NewModels() Models {
+func NewModels(db *sql.DB, dialect Dialect) Models {
+	models := newSQLModels(db, dialect)
+	models.db = db
+	models.dialect = dialect
+	return models
+}
+
+func newSQLModels(db DBTX, dialect Dialect) Models {
+	return Models{
+		Events:      EventModel{DB: db, Dialect: dialect},
+		Outbox:      OutboxModel{DB: db, Dialect: dialect},
+		Permissions: PermissionModel{DB: db, Dialect: dialect},
+		Tokens:      TokenModel{DB: db, Dialect: dialect},
+		Users:       UserModel{DB: db, Dialect: dialect},
+	}
+}
+
+// Returns the models, which keep all the data in memory. They are meant for
+// development and tests, the data is lost when the process exits.
+func NewMemoryModels() Models {
+	return newMemoryModels(newMemoryStore())
+}
+
+func newMemoryModels(store *memoryStore) Models {
 	return Models{
-		Users: UserModel{},
+		Events:      MemoryEventModel{store},
+		Outbox:      MemoryOutboxModel{store},
+		Permissions: MemoryPermissionModel{store},
+		Tokens:      MemoryTokenModel{store},
+		Users:       MemoryUserModel{store},
+		store:       store,
 	}
 }
 
-//func NewModels(db *sql.DB) Models {
-//	return Models{
-//		Users: UserModel{},
-//	}
-//}
+// WithTx calls fn with the models bound to a single transaction. The transaction
+// is committed if fn returns nil, and rolled back otherwise.
+// Models passed to fn must not be used after it returns.
+func (m Models) WithTx(fn func(tx Models) error) error {
+	if m.store != nil {
+		return m.store.withTx(func(tx *memoryStore) error {
+			return fn(newMemoryModels(tx))
+		})
+	}
+
+	tx, err := m.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(newSQLModels(tx, m.dialect))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestModelsWithTx(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		alice := newTestUser(t, "alice@example.com")
+		bob := newTestUser(t, "bob@example.com")
+		carol := newTestUser(t, "carol@example.com")
+
+		errRollback := errors.New("rollback")
+		inserted := make(chan struct{})
+		rolledBack := make(chan error)
+
+		// The transaction inserts alice and rolls back, while bob is inserted
+		// outside of a transaction and carol by another transaction.
+		go func() {
+			rolledBack <- models.WithTx(func(tx Models) error {
+				err := tx.Users.Insert(alice)
+				if err != nil {
+					return err
+				}
+				close(inserted)
+
+				// Let the other writes start before the rollback.
+				time.Sleep(50 * time.Millisecond)
+				return errRollback
+			})
+		}()
+
+		<-inserted
+
+		committed := make(chan error, 2)
+		go func() {
+			committed <- models.Users.Insert(bob)
+		}()
+		go func() {
+			committed <- models.WithTx(func(tx Models) error {
+				return tx.Users.Insert(carol)
+			})
+		}()
+
+		if err := <-rolledBack; err != errRollback {
+			t.Fatalf("got error %v; want %v", err, errRollback)
+		}
+		for i := 0; i < 2; i++ {
+			if err := <-committed; err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := models.Users.GetByEmail(alice.Email); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("rolled back user: got error %v; want %v", err, ErrRecordNotFound)
+		}
+		for _, email := range []string{bob.Email, carol.Email} {
+			if _, err := models.Users.GetByEmail(email); err != nil {
+				t.Errorf("committed user %s: got error %v", email, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Statuses of the outbox messages. Failed messages have reached
+// the maximum number of delivery attempts (dead letters).
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxMessage is an email waiting for delivery. It is written in the same
+// transaction as the change which triggers it, so the email is never lost.
+type OutboxMessage struct {
+	ID            int64
+	Recipient     string
+	Template      string
+	Data          map[string]interface{} // Stored as JSON, numbers are decoded as json.Number.
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	SentAt        *time.Time
+	RequestID     string // Of the request, which queued the message, for the dispatcher logs.
+	TraceID       string
+}
+
+type OutboxModel struct {
+	DB      DBTX
+	Dialect Dialect
+}
+
+// Insert a new pending message, which is due immediately.
+func (m OutboxModel) Insert(message *OutboxMessage) error {
+	data, err := json.Marshal(message.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO email_outbox (recipient, template, data, status, attempts, last_error, next_attempt_at, created_at, request_id, trace_id)
+		VALUES (?, ?, ?, ?, 0, '', ?, ?, ?, ?)`
+
+	now := time.Now().UTC().Truncate(time.Second)
+	args := []interface{}{message.Recipient, message.Template, string(data), OutboxPending, now, now, message.RequestID, message.TraceID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id, err := m.Dialect.insert(ctx, m.DB, query, "id", args...)
+	if err != nil {
+		return err
+	}
+
+	message.ID = id
+	message.Status = OutboxPending
+	message.Attempts = 0
+	message.LastError = ""
+	message.NextAttemptAt = now
+	message.CreatedAt = now
+	return nil
+}
+
+// Returns up to limit pending messages, which are due for a delivery attempt.
+func (m OutboxModel) GetDue(limit int) ([]*OutboxMessage, error) {
+	query := `
+		SELECT id, recipient, template, data, status, attempts, last_error, next_attempt_at, created_at, sent_at, request_id, trace_id
+		FROM email_outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(query), OutboxPending, time.Now().UTC().Truncate(time.Second), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []*OutboxMessage{}
+
+	for rows.Next() {
+		var message OutboxMessage
+		var data string
+
+		err := rows.Scan(
+			&message.ID,
+			&message.Recipient,
+			&message.Template,
+			&data,
+			&message.Status,
+			&message.Attempts,
+			&message.LastError,
+			&message.NextAttemptAt,
+			&message.CreatedAt,
+			&message.SentAt,
+			&message.RequestID,
+			&message.TraceID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		message.Data, err = decodeOutboxData(data)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Claim counts a delivery attempt and postpones the next one by the lease,
+// so the message isn't picked up again while it is being delivered. If the
+// delivery doesn't finish (the process exits), the message is retried after the lease.
+// Returns ErrEditConflict if the message has been claimed by someone else.
+func (m OutboxModel) Claim(message *OutboxMessage, lease time.Duration) error {
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id = ? AND attempts = ? AND status = ?`
+
+	nextAttemptAt := time.Now().UTC().Add(lease).Truncate(time.Second)
+	args := []interface{}{nextAttemptAt, message.ID, message.Attempts, OutboxPending}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	message.Attempts++
+	message.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+// Update the delivery state of the message. The data of the sent and failed
+// messages is cleared, as it holds the activation and password reset tokens.
+func (m OutboxModel) Update(message *OutboxMessage) error {
+	query := `
+		UPDATE email_outbox
+		SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ?,
+			data = CASE WHEN ? THEN data ELSE '{}' END
+		WHERE id = ?`
+
+	var sentAt *time.Time
+	if message.SentAt != nil {
+		t := message.SentAt.UTC().Truncate(time.Second)
+		sentAt = &t
+	}
+
+	args := []interface{}{
+		message.Status,
+		message.Attempts,
+		message.LastError,
+		message.NextAttemptAt.UTC().Truncate(time.Second),
+		sentAt,
+		message.Status == OutboxPending,
+		message.ID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if message.Status != OutboxPending {
+		message.Data = map[string]interface{}{}
+	}
+	return nil
+}
+
+// Deletes the sent and failed messages created before the time, and returns
+// the number of the deleted messages.
+func (m OutboxModel) DeleteFinished(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM email_outbox
+		WHERE status IN (?, ?) AND created_at < ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), OutboxSent, OutboxFailed, before.UTC().Truncate(time.Second))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Returns the number of messages in each status.
+func (m OutboxModel) Counts() (map[string]int, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM email_outbox
+		GROUP BY status`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{OutboxPending: 0, OutboxSent: 0, OutboxFailed: 0}
+
+	for rows.Next() {
+		var status string
+		var count int
+
+		err := rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func decodeOutboxData(data string) (map[string]interface{}, error) {
+	var m map[string]interface{}
+
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+
+	err := dec.Decode(&m)
+	return m, err
+}
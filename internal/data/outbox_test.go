@@ -0,0 +1,80 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		message := &OutboxMessage{
+			Recipient: "alice@example.com",
+			Template:  "user_welcome.tmpl",
+			Data:      map[string]interface{}{"activationToken": "XXXXXXXXXXXXXXXXXXXXXXXXXX"},
+			RequestID: "request",
+		}
+		if err := models.Outbox.Insert(message); err != nil {
+			t.Fatal(err)
+		}
+
+		due, err := models.Outbox.GetDue(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 1 || due[0].ID != message.ID || due[0].RequestID != "request" || due[0].Data["activationToken"] != "XXXXXXXXXXXXXXXXXXXXXXXXXX" {
+			t.Fatalf("got due messages %+v; want the inserted one", due)
+		}
+
+		// Only one of the dispatchers claims the message.
+		other := *due[0]
+		if err := models.Outbox.Claim(due[0], time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := models.Outbox.Claim(&other, time.Minute); !errors.Is(err, ErrEditConflict) {
+			t.Errorf("second claim: got error %v; want %v", err, ErrEditConflict)
+		}
+
+		// The claimed message isn't due until the lease expires.
+		due, err = models.Outbox.GetDue(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 0 {
+			t.Errorf("got %d due messages after the claim; want 0", len(due))
+		}
+
+		sentAt := time.Now()
+		message.Status = OutboxSent
+		message.Attempts = 1
+		message.SentAt = &sentAt
+		if err := models.Outbox.Update(message); err != nil {
+			t.Fatal(err)
+		}
+		if len(message.Data) != 0 {
+			t.Errorf("got data %v of a sent message; want it cleared", message.Data)
+		}
+
+		missing := &OutboxMessage{ID: 999, Status: OutboxSent}
+		if err := models.Outbox.Update(missing); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("update a missing message: got error %v; want %v", err, ErrRecordNotFound)
+		}
+
+		counts, err := models.Outbox.Counts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts[OutboxPending] != 0 || counts[OutboxSent] != 1 {
+			t.Errorf("got counts %v; want 1 sent message", counts)
+		}
+
+		// The finished messages are purged after the retention.
+		n, err := models.Outbox.DeleteFinished(time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("got %d purged messages; want 1", n)
+		}
+	})
+}
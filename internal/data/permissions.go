@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"strings"
+	"time"
+)
+
+// Permission codes.
+const (
+	PermissionEventsRead  = "events:read"
+	PermissionEventsWrite = "events:write"
+	PermissionUsersAdmin  = "users:admin"
+	// Grants the partner quota tier of the rate limiter.
+	PermissionQuotaPartner = "quota:partner"
+)
+
+// All known permission codes.
+var PermissionCodes = []string{PermissionEventsRead, PermissionEventsWrite, PermissionUsersAdmin, PermissionQuotaPartner}
+
+// Permissions granted to every newly registered user.
+var DefaultPermissions = []string{PermissionEventsRead}
+
+// Permissions holds the permission codes for a single user.
+type Permissions []string
+
+// Checks whether the Permissions slice contains a specific permission code.
+func (p Permissions) Include(code string) bool {
+	for i := range p {
+		if code == p[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidatePermissionCodes(v *validator.Validator, codes []string) {
+	v.Check(len(codes) != 0, "codes", "must contain at least 1 code")
+	v.Check(validator.Unique(codes), "codes", "must not contain duplicate values")
+	for _, code := range codes {
+		v.Check(validator.In(code, PermissionCodes...), "codes", "must contain only known permission codes")
+	}
+}
+
+type PermissionModel struct {
+	DB      DBTX
+	Dialect Dialect
+}
+
+// Returns all permission codes for a specific user.
+func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	query := `
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+		WHERE users_permissions.user_id = ?
+		ORDER BY permissions.code`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, m.Dialect.rebind(query), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+
+	for rows.Next() {
+		var permission string
+
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// Grants the provided permission codes to a specific user.
+// Codes which the user already has are skipped.
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO users_permissions (user_id, permission_id)
+		SELECT users.id, permissions.id FROM users, permissions
+		WHERE users.id = ?
+		AND permissions.code IN (` + placeholders(len(codes)) + `)
+		AND permissions.id NOT IN (
+			SELECT permission_id FROM users_permissions WHERE user_id = ?
+		)`
+
+	args := []interface{}{userID}
+	for _, code := range codes {
+		args = append(args, code)
+	}
+	args = append(args, userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	return err
+}
+
+// Revokes the provided permission codes from a specific user.
+func (m PermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+
+	query := `
+		DELETE FROM users_permissions
+		WHERE user_id = ?
+		AND permission_id IN (
+			SELECT id FROM permissions WHERE code IN (` + placeholders(len(codes)) + `)
+		)`
+
+	args := []interface{}{userID}
+	for _, code := range codes {
+		args = append(args, code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	return err
+}
+
+// Returns a comma-separated list of n query placeholders, e.g. "?, ?, ?".
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		user := newTestUser(t, "alice@example.com")
+		if err := models.Users.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			run  func() error
+			want Permissions
+		}{
+			{"none", func() error { return nil }, nil},
+			{"add", func() error {
+				return models.Permissions.AddForUser(user.ID, PermissionEventsWrite, PermissionEventsRead)
+			}, Permissions{PermissionEventsRead, PermissionEventsWrite}},
+			{"add again", func() error {
+				return models.Permissions.AddForUser(user.ID, PermissionEventsRead, PermissionUsersAdmin)
+			}, Permissions{PermissionEventsRead, PermissionEventsWrite, PermissionUsersAdmin}},
+			{"remove", func() error {
+				return models.Permissions.RemoveForUser(user.ID, PermissionEventsWrite, PermissionQuotaPartner)
+			}, Permissions{PermissionEventsRead, PermissionUsersAdmin}},
+		}
+
+		for _, tt := range tests {
+			if err := tt.run(); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+
+			got, err := models.Permissions.GetAllForUser(user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A user without permissions may get a nil or an empty slice.
+			if (len(got) != 0 || len(tt.want) != 0) && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %v; want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
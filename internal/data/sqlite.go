@@ -0,0 +1,20 @@
+package data
+
+import (
+	"database/sql/driver"
+	sqlite "github.com/glebarez/go-sqlite"
+	"strings"
+)
+
+// The built-in LOWER() of SQLite folds only the ASCII letters, so the
+// case-insensitive title filter of EventModel.GetAll wouldn't match e.g. a
+// Cyrillic title in another case. It's replaced on the SQLite connections by
+// strings.ToLower, which lowercases the pattern and the memory models as well.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		if s, ok := args[0].(string); ok {
+			return strings.ToLower(s), nil
+		}
+		return args[0], nil
+	})
+}
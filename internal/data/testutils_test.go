@@ -0,0 +1,60 @@
+package data
+
+import (
+	"database/sql"
+	"github.com/ol-ilyassov/test/internal/migrate"
+	"github.com/ol-ilyassov/test/migrations"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+// Runs fn with new memory models and with new SQL models on a migrated SQLite
+// database in memory, so both implementations go through the same cases.
+func runModels(t *testing.T, fn func(t *testing.T, models Models)) {
+	t.Helper()
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewMemoryModels())
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		fn(t, newSQLiteModels(t))
+	})
+}
+
+func newSQLiteModels(t *testing.T) Models {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each connection to ":memory:" opens a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrate.New(db, string(DialectSQLite), migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewModels(db, DialectSQLite)
+}
+
+// Returns a new user with the email, who isn't inserted yet.
+func newTestUser(t *testing.T, email string) *User {
+	t.Helper()
+
+	// Fast password hashing.
+	PasswordCost = bcrypt.MinCost
+
+	user := &User{Name: "Test", Email: email}
+	err := user.Password.Set("pa55word123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		user := newTestUser(t, "alice@example.com")
+		if err := models.Users.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+
+		newToken := func(ttl time.Duration, scope string) string {
+			token, err := models.Tokens.New(user.ID, ttl, scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token.Plaintext
+		}
+
+		activation := newToken(time.Hour, ScopeActivation)
+		authentication := newToken(time.Hour, ScopeAuthentication)
+		expired := newToken(-time.Second, ScopeAuthentication)
+
+		tests := []struct {
+			name      string
+			scope     string
+			plaintext string
+			wantErr   error
+		}{
+			{"valid", ScopeActivation, activation, nil},
+			{"another scope", ScopeAuthentication, activation, ErrRecordNotFound},
+			{"expired", ScopeAuthentication, expired, ErrRecordNotFound},
+			{"unknown", ScopeAuthentication, "XXXXXXXXXXXXXXXXXXXXXXXXXX", ErrRecordNotFound},
+		}
+
+		for _, tt := range tests {
+			got, err := models.Users.GetForToken(tt.scope, tt.plaintext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got error %v; want %v", tt.name, err, tt.wantErr)
+				continue
+			}
+			if err == nil && got.ID != user.ID {
+				t.Errorf("%s: got user ID %d; want %d", tt.name, got.ID, user.ID)
+			}
+		}
+
+		// Only the tokens with the scope are deleted.
+		err := models.Tokens.DeleteAllForUser(ScopeActivation, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.Users.GetForToken(ScopeActivation, activation); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("deleted token: got error %v; want %v", err, ErrRecordNotFound)
+		}
+		if _, err := models.Users.GetForToken(ScopeAuthentication, authentication); err != nil {
+			t.Errorf("token of another scope: got error %v", err)
+		}
+
+		// The tokens of a deleted user are deleted as well.
+		err = models.Users.Delete(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := models.Users.GetForToken(ScopeAuthentication, authentication); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("token of a deleted user: got error %v; want %v", err, ErrRecordNotFound)
+		}
+	})
+}
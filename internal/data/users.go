@@ -1,10 +1,27 @@
 package data
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"errors"
+	"github.com/ol-ilyassov/test/internal/validator"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
+var (
+	ErrDuplicateEmail = errors.New("duplicate email")
+)
+
+// PasswordCost is the bcrypt work factor used for new password hashes.
+// Hashes created with a lower cost are upgraded on the next successful login.
+var PasswordCost = 12
+
+// AnonymousUser represents a request without an authentication token.
+var AnonymousUser = &User{}
+
 type User struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -15,11 +32,278 @@ type User struct {
 	Version   int       `json:"-"` // !
 }
 
+// Check if a User instance is the AnonymousUser.
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
 type password struct {
 	plaintext *string // distinguish between not being present versus empty string
 	hash      []byte
 }
 
+// Calculate the bcrypt hash of a plaintext password, and store both values in the struct.
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), PasswordCost)
+	if err != nil {
+		return err
+	}
+	p.plaintext = &plaintextPassword
+	p.hash = hash
+	return nil
+}
+
+// Check whether the provided plaintext password matches the hashed password.
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Report whether the stored hash was created with weaker parameters than
+// the current PasswordCost. The cost is encoded in the hash itself.
+func (p *password) NeedsRehash() bool {
+	cost, err := bcrypt.Cost(p.hash)
+	if err != nil {
+		return true
+	}
+	return cost < PasswordCost
+}
+
+// Returns the email address in lowercase, the form in which it's stored and
+// looked up. The unique index and the lookups compare the emails exactly on
+// PostgreSQL and SQLite, so every email from the clients goes through it.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(email)
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	v.Check(user.Name != "", "name", "must be provided")
+	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+
+	ValidateEmail(v, user.Email)
+
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+
+	// If the password hash is ever nil, this will be due to a logic error in our
+	// codebase, not a problem with the user input.
+	if user.Password.hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
 type UserModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Dialect Dialect
+}
+
+// Insert a new record in the database for the user.
+// ID, CreatedAt and Version fields are filled in after the insert.
+func (m UserModel) Insert(user *User) error {
+	query := `
+		INSERT INTO users (created_at, name, email, password_hash, activated, version)
+		VALUES (?, ?, ?, ?, ?, 1)`
+
+	user.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	args := []interface{}{user.CreatedAt, user.Name, user.Email, user.Password.hash, user.Activated}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	id, err := m.Dialect.insert(ctx, m.DB, query, "id", args...)
+	if err != nil {
+		switch {
+		case m.Dialect.isUniqueViolation(err, "email"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	user.ID = id
+	user.Version = 1
+	return nil
+}
+
+// Retrieve the user with the given ID.
+func (m UserModel) GetByID(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE id = ?`
+
+	return m.get(query, id)
+}
+
+// Retrieve the user details from the database based on the email address.
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, version
+		FROM users
+		WHERE email = ?`
+
+	return m.get(query, email)
+}
+
+// Retrieve the user associated with a valid (not expired) token of the given scope.
+func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		FROM users
+		INNER JOIN tokens
+		ON users.id = tokens.user_id
+		WHERE tokens.hash = ?
+		AND tokens.scope = ?
+		AND tokens.expiry > ?`
+
+	return m.get(query, tokenHash[:], tokenScope, time.Now().UTC())
+}
+
+func (m UserModel) get(query string, args ...interface{}) (*User, error) {
+	var user User
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, m.Dialect.rebind(query), args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// Update the details for a specific user.
+// The version number is checked to prevent race conditions (optimistic locking):
+// if the record was changed since it had been read, ErrEditConflict is returned.
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET name = ?, email = ?, password_hash = ?, activated = ?, version = version + 1
+		WHERE id = ? AND version = ?`
+
+	args := []interface{}{
+		user.Name,
+		user.Email,
+		user.Password.hash,
+		user.Activated,
+		user.ID,
+		user.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), args...)
+	if err != nil {
+		switch {
+		case m.Dialect.isUniqueViolation(err, "email"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	// No rows updated: the version has been changed (or the record deleted) in the meantime.
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	user.Version++
+	return nil
+}
+
+// Re-hash the password with the current PasswordCost after a successful login,
+// if the stored hash is weaker.
+func (m UserModel) UpgradePassword(user *User, plaintextPassword string) error {
+	return upgradePassword(m.Update, user, plaintextPassword)
+}
+
+// An edit conflict means that the user record has been changed concurrently,
+// so the upgrade is simply left for the next login.
+func upgradePassword(update func(*User) error, user *User, plaintextPassword string) error {
+	if !user.Password.NeedsRehash() {
+		return nil
+	}
+
+	err := user.Password.Set(plaintextPassword)
+	if err != nil {
+		return err
+	}
+
+	err = update(user)
+	if err != nil && !errors.Is(err, ErrEditConflict) {
+		return err
+	}
+	return nil
+}
+
+// Delete the user with the given ID.
+func (m UserModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM users
+		WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, m.Dialect.rebind(query), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
 }
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUsers(t *testing.T) {
+	runModels(t, func(t *testing.T, models Models) {
+		alice := newTestUser(t, "alice@example.com")
+		if err := models.Users.Insert(alice); err != nil {
+			t.Fatal(err)
+		}
+		bob := newTestUser(t, "bob@example.com")
+		if err := models.Users.Insert(bob); err != nil {
+			t.Fatal(err)
+		}
+		if alice.ID == 0 || alice.Version != 1 {
+			t.Fatalf("got ID %d, version %d; want a new ID, version 1", alice.ID, alice.Version)
+		}
+
+		tests := []struct {
+			name    string
+			run     func() error
+			wantErr error
+		}{
+			{"insert a duplicate email", func() error {
+				return models.Users.Insert(newTestUser(t, "alice@example.com"))
+			}, ErrDuplicateEmail},
+			{"get by a missing ID", func() error {
+				_, err := models.Users.GetByID(999)
+				return err
+			}, ErrRecordNotFound},
+			{"get by a missing email", func() error {
+				_, err := models.Users.GetByEmail("carol@example.com")
+				return err
+			}, ErrRecordNotFound},
+			{"get by the email", func() error {
+				user, err := models.Users.GetByEmail("alice@example.com")
+				if err == nil && user.ID != alice.ID {
+					t.Errorf("got user ID %d; want %d", user.ID, alice.ID)
+				}
+				return err
+			}, nil},
+			{"update to a taken email", func() error {
+				user, err := models.Users.GetByID(bob.ID)
+				if err != nil {
+					return err
+				}
+				user.Email = "alice@example.com"
+				return models.Users.Update(user)
+			}, ErrDuplicateEmail},
+			{"update", func() error {
+				user, err := models.Users.GetByID(alice.ID)
+				if err != nil {
+					return err
+				}
+				user.Activated = true
+				err = models.Users.Update(user)
+				if err == nil && user.Version != 2 {
+					t.Errorf("got version %d; want 2", user.Version)
+				}
+				return err
+			}, nil},
+			{"update a stale version", func() error {
+				// alice still has the version 1.
+				alice.Name = "Alice"
+				return models.Users.Update(alice)
+			}, ErrEditConflict},
+			{"delete", func() error {
+				return models.Users.Delete(bob.ID)
+			}, nil},
+			{"delete a missing ID", func() error {
+				return models.Users.Delete(bob.ID)
+			}, ErrRecordNotFound},
+		}
+
+		for _, tt := range tests {
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got error %v; want %v", tt.name, err, tt.wantErr)
+			}
+		}
+	})
+}
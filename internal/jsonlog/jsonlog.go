@@ -1,6 +1,7 @@
 package jsonlog
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"os"
@@ -57,6 +58,43 @@ func (l *Logger) PrintFatal(err error, properties map[string]string) {
 	os.Exit(1) // For entries at the FATAL level, we also terminate the application.
 }
 
+// Custom type for the context key of the properties, to avoid collisions with other packages.
+type contextKey struct{}
+
+// NewContext returns a copy of ctx with the properties (e.g. the request and trace IDs),
+// which the *Context methods add to each entry logged with it.
+func NewContext(ctx context.Context, properties map[string]string) context.Context {
+	return context.WithValue(ctx, contextKey{}, merge(fromContext(ctx), properties))
+}
+
+func fromContext(ctx context.Context) map[string]string {
+	properties, _ := ctx.Value(contextKey{}).(map[string]string)
+	return properties
+}
+
+// Returns a new map with the properties of both, b takes precedence.
+func merge(a, b map[string]string) map[string]string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (l *Logger) PrintInfoContext(ctx context.Context, message string, properties map[string]string) {
+	l.print(LevelInfo, message, merge(fromContext(ctx), properties))
+}
+func (l *Logger) PrintErrorContext(ctx context.Context, err error, properties map[string]string) {
+	l.print(LevelError, err.Error(), merge(fromContext(ctx), properties))
+}
+
 func (l *Logger) print(level Level, message string, properties map[string]string) (int, error) {
 	// Return with no further action if severity level below of minimum.
 	if level < l.minLevel {
@@ -0,0 +1,166 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Email templates are embedded into the binary.
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer renders emails from the embedded templates and hands them to the
+// transport, with the sender information ("Name <email@example.com>").
+type Mailer struct {
+	transport Transport
+	sender    string
+}
+
+func New(transport Transport, sender string) Mailer {
+	return Mailer{
+		transport: transport,
+		sender:    sender,
+	}
+}
+
+// Send renders the "subject", "plainBody" and "htmlBody" templates from the given
+// template file with dynamic data, and delivers the email to the recipient.
+func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := m.render(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	return m.transport.Deliver(envelopeAddress(m.sender), []string{recipient}, msg)
+}
+
+// Messages returns the emails captured by the transport, newest first.
+// Returns ErrNotCaptured if the transport doesn't keep sent emails (SMTP).
+func (m Mailer) Messages() ([]Message, error) {
+	c, ok := m.transport.(capturer)
+	if !ok {
+		return nil, ErrNotCaptured
+	}
+	return c.Messages()
+}
+
+// Builds a multipart/alternative MIME message with plain text and HTML parts.
+func (m Mailer) render(recipient, templateFile string, data interface{}) ([]byte, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HTML body is rendered with html/template, so the data is escaped.
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(bytes.Buffer)
+	mw := multipart.NewWriter(msg)
+
+	messageID, err := newMessageID(envelopeAddress(m.sender))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(msg, "From: %s\r\n", m.sender)
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%q\r\n", mw.Boundary())
+	fmt.Fprintf(msg, "\r\n")
+
+	// Parts in order of increasing preference: plain text first, then HTML.
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", plainBody.String()},
+		{"text/html; charset=UTF-8", htmlBody.String()},
+	}
+
+	for _, part := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		_, err = qp.Write([]byte(strings.ReplaceAll(strings.TrimSpace(part.body), "\n", "\r\n")))
+		if err != nil {
+			return nil, err
+		}
+		err = qp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// Generates a unique Message-ID header value in the sender's domain.
+func newMessageID(sender string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(sender, "@"); i != -1 {
+		domain = sender[i+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
+
+// Extract the bare email address from "Name <email@example.com>".
+func envelopeAddress(sender string) string {
+	start := strings.LastIndex(sender, "<")
+	end := strings.LastIndex(sender, ">")
+	if start == -1 || end < start {
+		return strings.TrimSpace(sender)
+	}
+	return sender[start+1 : end]
+}
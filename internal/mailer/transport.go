@@ -0,0 +1,238 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/jsonlog"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotCaptured = errors.New("mailer: the transport doesn't capture messages")
+
+// Maximum number of captured messages listed by the file and log transports.
+const maxCaptured = 100
+
+// Transport delivers a rendered MIME message.
+type Transport interface {
+	Deliver(from string, to []string, msg []byte) error
+}
+
+// Implemented by the transports which keep the delivered messages.
+type capturer interface {
+	Messages() ([]Message, error)
+}
+
+// Message is a captured email.
+type Message struct {
+	ID      string
+	Time    time.Time
+	From    string
+	To      string
+	Subject string
+	Body    string // Plain text part.
+}
+
+// SMTP.
+
+type SMTPTransport struct {
+	addr string
+	auth smtp.Auth
+}
+
+func NewSMTPTransport(host string, port int, username, password string) *SMTPTransport {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPTransport{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		auth: auth,
+	}
+}
+
+// A single attempt: the transient failures are retried by the email outbox
+// with its own backoff, without blocking the dispatcher here. The permanent
+// ones are reported by IsPermanent.
+func (t *SMTPTransport) Deliver(from string, to []string, msg []byte) error {
+	return smtp.SendMail(t.addr, t.auth, from, to, msg)
+}
+
+// Reports whether the delivery failed permanently, so a retry can't succeed:
+// the SMTP server replied with a 5xx code, e.g. an unknown mailbox or a denied
+// relay. Connection errors and 4xx replies are transient.
+func IsPermanent(err error) bool {
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 500
+}
+
+// File.
+
+// FileTransport writes each message as an .eml file into a directory.
+type FileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTransport{dir: dir}, nil
+}
+
+func (t *FileTransport) Deliver(from string, to []string, msg []byte) error {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return err
+	}
+
+	// The timestamp prefix keeps the files in delivery order.
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(b))
+
+	return ioutil.WriteFile(filepath.Join(t.dir, name), msg, 0644)
+}
+
+func (t *FileTransport) Messages() ([]Message, error) {
+	names, err := filepath.Glob(filepath.Join(t.dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	if len(names) > maxCaptured {
+		names = names[:maxCaptured]
+	}
+
+	messages := make([]Message, 0, len(names))
+	for _, name := range names {
+		raw, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		message, err := parseMessage(filepath.Base(name), raw)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Log.
+
+// LogTransport logs the headers of each message, and keeps the latest
+// messages in memory for the /debug/mail page.
+type LogTransport struct {
+	logger *jsonlog.Logger
+
+	mu       sync.Mutex
+	messages []Message
+	lastID   int
+}
+
+func NewLogTransport(logger *jsonlog.Logger) *LogTransport {
+	return &LogTransport{logger: logger}
+}
+
+func (t *LogTransport) Deliver(from string, to []string, msg []byte) error {
+	t.mu.Lock()
+	t.lastID++
+	id := fmt.Sprint(t.lastID)
+	t.mu.Unlock()
+
+	message, err := parseMessage(id, msg)
+	if err != nil {
+		return err
+	}
+
+	// The body isn't logged, it holds the activation and password reset tokens.
+	t.logger.PrintInfo("email captured", map[string]string{
+		"id":      id,
+		"from":    from,
+		"to":      strings.Join(to, ", "),
+		"subject": message.Subject,
+		"size":    strconv.Itoa(len(msg)),
+	})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, message)
+	if len(t.messages) > maxCaptured {
+		t.messages = t.messages[len(t.messages)-maxCaptured:]
+	}
+	return nil
+}
+
+func (t *LogTransport) Messages() ([]Message, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	messages := make([]Message, len(t.messages))
+	for i, message := range t.messages {
+		messages[len(messages)-1-i] = message
+	}
+	return messages, nil
+}
+
+// Extracts the headers and the plain text part of a message built by Mailer.
+func parseMessage(id string, raw []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, err
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	message := Message{
+		ID:      id,
+		From:    msg.Header.Get("From"),
+		To:      msg.Header.Get("To"),
+		Subject: subject,
+	}
+	message.Time, _ = msg.Header.Date()
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	// NextPart decodes the quoted-printable parts.
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+			body, err := ioutil.ReadAll(part)
+			if err != nil {
+				return Message{}, err
+			}
+			message.Body = string(body)
+			break
+		}
+	}
+	return message, nil
+}
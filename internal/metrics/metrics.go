@@ -0,0 +1,297 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds, from 5ms to 10s.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics, and writes them in the Prometheus text exposition format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(buf *bytes.Buffer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (reg *Registry) register(m metric) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.metrics = append(reg.metrics, m)
+}
+
+// Handler returns the handler of the /metrics endpoint.
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+
+		reg.mu.Lock()
+		for _, m := range reg.metrics {
+			m.write(&buf)
+		}
+		reg.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// Name, help and label names of a metric family.
+type desc struct {
+	name   string
+	help   string
+	typ    string // counter|gauge|histogram
+	labels []string
+}
+
+func (d *desc) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// Writes a sample line: name{label="value",...} 1.5
+func (d *desc) writeSample(buf *bytes.Buffer, suffix string, values []string, extraLabel, extraValue string, v float64) {
+	buf.WriteString(d.name + suffix)
+
+	if len(values) != 0 || extraLabel != "" {
+		buf.WriteByte('{')
+		for i, value := range values {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(d.labels[i] + `="` + escapeLabel(value) + `"`)
+		}
+		if extraLabel != "" {
+			if len(values) != 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(extraLabel + `="` + extraValue + `"`)
+		}
+		buf.WriteByte('}')
+	}
+
+	buf.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Returns the indexes of the series, ordered by their keys for a stable output.
+func sortedSeries(n int, key func(i int) string) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return key(idx[a]) < key(idx[b]) })
+	return idx
+}
+
+// Identifies the series by its label values.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// A family of float series (counter or gauge), one per label values.
+type vec struct {
+	desc
+	mu     sync.Mutex
+	keys   map[string]int
+	values [][]string
+	series []float64
+}
+
+func (v *vec) add(delta float64, values []string, set bool) {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := seriesKey(values)
+	i, ok := v.keys[key]
+	if !ok {
+		i = len(v.series)
+		v.keys[key] = i
+		v.values = append(v.values, append([]string(nil), values...))
+		v.series = append(v.series, 0)
+	}
+
+	if set {
+		v.series[i] = delta
+		return
+	}
+	v.series[i] += delta
+}
+
+func (v *vec) write(buf *bytes.Buffer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.writeHeader(buf)
+	for _, i := range sortedSeries(len(v.series), func(i int) string { return seriesKey(v.values[i]) }) {
+		v.writeSample(buf, "", v.values[i], "", "", v.series[i])
+	}
+}
+
+// CounterVec counts events, e.g. the requests, by the label values.
+type CounterVec struct {
+	vec
+}
+
+func (reg *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec{desc: desc{name: name, help: help, typ: "counter", labels: labels}, keys: make(map[string]int)}}
+	reg.register(c)
+	return c
+}
+
+// Inc increments the counter of the label values by 1.
+func (c *CounterVec) Inc(values ...string) {
+	c.add(1, values, false)
+}
+
+// Add adds the non-negative delta to the counter of the label values.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't decrease", c.name))
+	}
+	c.add(delta, values, false)
+}
+
+// GaugeVec holds values, which go up and down, by the label values.
+type GaugeVec struct {
+	vec
+}
+
+func (reg *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vec{desc: desc{name: name, help: help, typ: "gauge", labels: labels}, keys: make(map[string]int)}}
+	reg.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, values ...string) {
+	g.add(value, values, true)
+}
+
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	g.add(delta, values, false)
+}
+
+// HistogramVec counts the observations (e.g. latencies) in buckets by the label values.
+type HistogramVec struct {
+	desc
+	buckets []float64 // Upper bounds, sorted.
+	mu      sync.Mutex
+	keys    map[string]int
+	values  [][]string
+	series  []*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, the last one is +Inf.
+	sum    float64
+	count  uint64
+}
+
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		keys:    make(map[string]int),
+	}
+	reg.register(h)
+	return h
+}
+
+// Observe adds the value to the histogram of the label values.
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labels), len(values)))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := seriesKey(values)
+	i, ok := h.keys[key]
+	if !ok {
+		i = len(h.series)
+		h.keys[key] = i
+		h.values = append(h.values, append([]string(nil), values...))
+		h.series = append(h.series, &histogram{counts: make([]uint64, len(h.buckets)+1)})
+	}
+
+	s := h.series[i]
+	s.counts[sort.SearchFloat64s(h.buckets, value)]++
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(buf *bytes.Buffer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(buf)
+	for _, i := range sortedSeries(len(h.series), func(i int) string { return seriesKey(h.values[i]) }) {
+		s := h.series[i]
+
+		// The buckets are cumulative in the exposition format.
+		var cumulative uint64
+		for b, bound := range h.buckets {
+			cumulative += s.counts[b]
+			h.writeSample(buf, "_bucket", h.values[i], "le", formatFloat(bound), float64(cumulative))
+		}
+		h.writeSample(buf, "_bucket", h.values[i], "le", "+Inf", float64(s.count))
+		h.writeSample(buf, "_sum", h.values[i], "", "", s.sum)
+		h.writeSample(buf, "_count", h.values[i], "", "", float64(s.count))
+	}
+}
+
+// funcMetric is a metric without labels, whose value is read on each scrape.
+type funcMetric struct {
+	desc
+	fn func() float64
+}
+
+func (f *funcMetric) write(buf *bytes.Buffer) {
+	f.writeHeader(buf)
+	f.writeSample(buf, "", nil, "", "", f.fn())
+}
+
+// NewGaugeFunc registers the gauge, which value is returned by fn on each scrape.
+func (reg *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	reg.register(&funcMetric{desc: desc{name: name, help: help, typ: "gauge"}, fn: fn})
+}
+
+// NewCounterFunc registers the counter, which value is returned by fn on each scrape.
+func (reg *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	reg.register(&funcMetric{desc: desc{name: name, help: help, typ: "counter"}, fn: fn})
+}
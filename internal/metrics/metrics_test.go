@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Returns the exposition of the registry, served by its handler.
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	b, err := ioutil.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExposition(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(reg *Registry)
+		want  string
+	}{
+		{
+			name: "counter",
+			setup: func(reg *Registry) {
+				c := reg.NewCounterVec("http_requests_total", "Total HTTP requests.", "method", "status")
+				c.Inc("POST", "2xx")
+				c.Inc("GET", "2xx")
+				c.Add(2.5, "GET", "2xx")
+			},
+			want: `# HELP http_requests_total Total HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",status="2xx"} 3.5
+http_requests_total{method="POST",status="2xx"} 1
+`,
+		},
+		{
+			name: "gauge",
+			setup: func(reg *Registry) {
+				g := reg.NewGaugeVec("in_flight", "Requests in flight.", "route")
+				g.Add(2, "/v1/events")
+				g.Add(-1, "/v1/events")
+				g.Set(7, "/")
+			},
+			want: `# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight{route="/"} 7
+in_flight{route="/v1/events"} 1
+`,
+		},
+		{
+			name: "escaping",
+			setup: func(reg *Registry) {
+				c := reg.NewCounterVec("escaped_total", "Help with \\ and\nnewline.", "path")
+				c.Inc("a\"b\\c\nd")
+			},
+			want: `# HELP escaped_total Help with \\ and\nnewline.
+# TYPE escaped_total counter
+escaped_total{path="a\"b\\c\nd"} 1
+`,
+		},
+		{
+			name: "histogram",
+			setup: func(reg *Registry) {
+				// The buckets are sorted, and the bounds are inclusive.
+				h := reg.NewHistogramVec("duration_seconds", "Latency.", []float64{1, 0.5}, "route")
+				h.Observe(0.5, "/")
+				h.Observe(0.75, "/")
+				h.Observe(3, "/")
+			},
+			want: `# HELP duration_seconds Latency.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/",le="0.5"} 1
+duration_seconds_bucket{route="/",le="1"} 2
+duration_seconds_bucket{route="/",le="+Inf"} 3
+duration_seconds_sum{route="/"} 4.25
+duration_seconds_count{route="/"} 3
+`,
+		},
+		{
+			name: "funcs",
+			setup: func(reg *Registry) {
+				reg.NewGaugeFunc("temperature", "Current temperature.", func() float64 { return math.Inf(-1) })
+				reg.NewCounterFunc("ticks_total", "Ticks.", func() float64 { return 1e21 })
+			},
+			want: `# HELP temperature Current temperature.
+# TYPE temperature gauge
+temperature -Inf
+# HELP ticks_total Ticks.
+# TYPE ticks_total counter
+ticks_total 1e+21
+`,
+		},
+		{
+			name: "no series",
+			setup: func(reg *Registry) {
+				reg.NewCounterVec("empty_total", "Nothing yet.", "route")
+			},
+			want: `# HELP empty_total Nothing yet.
+# TYPE empty_total counter
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			tt.setup(reg)
+
+			if got := scrape(t, reg); got != tt.want {
+				t.Errorf("got exposition:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelValuesMismatch(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("requests_total", "Requests.", "method")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for the wrong number of label values")
+		}
+	}()
+	c.Inc("GET", "2xx")
+}
+
+func TestRuntime(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterRuntime()
+
+	got := scrape(t, reg)
+	for _, want := range []string{"# TYPE go_info gauge\ngo_info{version=", "\ngo_goroutines ", "\nprocess_start_time_seconds "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the exposition doesn't contain %q", want)
+		}
+	}
+}
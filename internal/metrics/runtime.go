@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"bytes"
+	"runtime"
+	"time"
+)
+
+// Go runtime and process stats. The memory stats are read once per scrape,
+// as runtime.ReadMemStats stops the world.
+type runtimeMetrics struct {
+	start time.Time
+}
+
+// RegisterRuntime registers the go_* and process_* metrics.
+func (reg *Registry) RegisterRuntime() {
+	reg.register(&runtimeMetrics{start: time.Now()})
+}
+
+func (m *runtimeMetrics) write(buf *bytes.Buffer) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	gauge := func(name, help string, v float64) {
+		d := desc{name: name, help: help, typ: "gauge"}
+		d.writeHeader(buf)
+		d.writeSample(buf, "", nil, "", "", v)
+	}
+	counter := func(name, help string, v float64) {
+		d := desc{name: name, help: help, typ: "counter"}
+		d.writeHeader(buf)
+		d.writeSample(buf, "", nil, "", "", v)
+	}
+
+	info := desc{name: "go_info", help: "Information about the Go environment.", typ: "gauge", labels: []string{"version"}}
+	info.writeHeader(buf)
+	info.writeSample(buf, "", []string{runtime.Version()}, "", "", 1)
+
+	gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(stats.Alloc))
+	counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(stats.TotalAlloc))
+	gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(stats.Sys))
+	gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(stats.HeapInuse))
+	gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(stats.HeapObjects))
+	gauge("go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(stats.StackInuse))
+	counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(stats.NumGC))
+	counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time in seconds.", float64(stats.PauseTotalNs)/1e9)
+	gauge("go_gomaxprocs", "Value of GOMAXPROCS.", float64(runtime.GOMAXPROCS(0)))
+	gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(m.start.UnixNano())/1e9)
+}
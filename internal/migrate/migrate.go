@@ -0,0 +1,551 @@
+// Package migrate applies versioned SQL migrations and keeps track of them in
+// the schema_migrations table. Concurrent runs are prevented with a lock row in
+// the schema_lock table, and each applied migration is stored with the checksum
+// of its script, so modified migration files are detected.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/ol-ilyassov/test/internal/sqldialect"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLocked           = errors.New("migrations are locked by another process")
+	ErrDirty            = errors.New("database is in a dirty state")
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	ErrNoMigration      = errors.New("no such migration")
+)
+
+// Migration is a pair of up and down SQL scripts with a version number.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // Hex encoded SHA-256 of the up script.
+}
+
+// Status describes the state of a single migration.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	Dirty     bool // The migration failed half-way and the schema needs a manual fix.
+	Modified  bool // The migration file has been changed after it was applied.
+	Missing   bool // The migration is applied, but its file doesn't exist anymore.
+}
+
+type applied struct {
+	version   int64
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Migrator runs the migrations of a single driver against the database.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+}
+
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// New loads the migrations from the directory of fsys named after the driver
+// (mysql|postgres|sqlite).
+func New(db *sql.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		matches := filenameRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(driver, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, mig.Name, matches[2])
+		}
+
+		switch matches[3] {
+		case "up":
+			mig.Up = string(script)
+			sum := sha256.Sum256(script)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		case "down":
+			mig.Down = string(script)
+		}
+	}
+
+	m := &Migrator{db: db, driver: driver}
+
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		m.migrations = append(m.migrations, *mig)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	return m, nil
+}
+
+// Up applies the pending migrations in ascending order. If steps is positive,
+// no more than steps migrations are applied. The applied migrations are returned.
+func (m *Migrator) Up(steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(func() error {
+		state, err := m.verifiedState()
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			if _, ok := state[mig.Version]; ok {
+				continue
+			}
+
+			err = m.apply(mig)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest applied migrations in descending order.
+// If steps is not positive, a single migration is rolled back.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+
+	var done []Migration
+
+	err := m.withLock(func() error {
+		state, err := m.verifiedState()
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(state))
+		for version := range state {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(done) == steps {
+				break
+			}
+
+			mig, ok := m.find(version)
+			if !ok {
+				return fmt.Errorf("migration %d: %w", version, ErrNoMigration)
+			}
+
+			err = m.revert(mig)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force marks all the known migrations up to and including the version as applied and
+// clean, and the later ones as not applied, without running any scripts. A stale lock
+// is released as well. It is used to recover after a failed migration has been fixed manually.
+func (m *Migrator) Force(version int64) error {
+	if version != 0 {
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("migration %d: %w", version, ErrNoMigration)
+		}
+	}
+
+	err := m.ensureTables()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.exec(`DELETE FROM schema_lock`)
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(func() error {
+		state, err := m.state()
+		if err != nil {
+			return err
+		}
+
+		for v := range state {
+			if v > version {
+				_, err = m.exec(`DELETE FROM schema_migrations WHERE version = ?`, v)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+
+			a, ok := state[mig.Version]
+			switch {
+			case !ok:
+				_, err = m.exec(`
+					INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at)
+					VALUES (?, ?, ?, ?, ?)`,
+					mig.Version, mig.Name, mig.Checksum, false, now())
+			case a.dirty || a.checksum != mig.Checksum:
+				_, err = m.exec(`
+					UPDATE schema_migrations SET checksum = ?, dirty = ?
+					WHERE version = ?`,
+					mig.Checksum, false, mig.Version)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status returns the state of all the known and applied migrations, ordered by version.
+func (m *Migrator) Status() ([]Status, error) {
+	err := m.ensureTables()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := m.state()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := state[mig.Version]; ok {
+			appliedAt := a.appliedAt
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+			s.Dirty = a.dirty
+			s.Modified = a.checksum != mig.Checksum
+			delete(state, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+
+	for _, a := range state {
+		appliedAt := a.appliedAt
+		statuses = append(statuses, Status{
+			Version:   a.version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Dirty:     a.dirty,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending returns the number of migrations, which are not applied or are dirty.
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied || s.Dirty {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Runs the up script. The migration is recorded as dirty before the script is
+// executed, because DDL statements can't be rolled back in MySQL.
+func (m *Migrator) apply(mig Migration) error {
+	_, err := m.exec(`
+		INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		mig.Version, mig.Name, mig.Checksum, true, now())
+	if err != nil {
+		return err
+	}
+
+	err = m.run(mig.Up)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.exec(`UPDATE schema_migrations SET dirty = ? WHERE version = ?`, false, mig.Version)
+	return err
+}
+
+func (m *Migrator) revert(mig Migration) error {
+	_, err := m.exec(`UPDATE schema_migrations SET dirty = ? WHERE version = ?`, true, mig.Version)
+	if err != nil {
+		return err
+	}
+
+	err = m.run(mig.Down)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.exec(`DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+	return err
+}
+
+// Executes the statements of the script in a single transaction.
+func (m *Migrator) run(script string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Returns the applied migrations, after checking that none of them is dirty or modified.
+func (m *Migrator) verifiedState() (map[int64]applied, error) {
+	state, err := m.state()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range state {
+		if a.dirty {
+			return nil, fmt.Errorf("%w at version %d, fix the schema and run 'migrate force %d'", ErrDirty, a.version, a.version)
+		}
+		if mig, ok := m.find(a.version); ok && mig.Checksum != a.checksum {
+			return nil, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, ErrChecksumMismatch)
+		}
+	}
+	return state, nil
+}
+
+func (m *Migrator) state() (map[int64]applied, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT version, name, checksum, dirty, applied_at
+		FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	state := make(map[int64]applied)
+
+	for rows.Next() {
+		var a applied
+
+		err := rows.Scan(&a.version, &a.name, &a.checksum, &a.dirty, &a.appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		state[a.version] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Runs fn while holding the lock row in the schema_lock table.
+func (m *Migrator) withLock(fn func() error) error {
+	err := m.ensureTables()
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+
+	_, err = m.exec(`INSERT INTO schema_lock (id, owner, locked_at) VALUES (?, ?, ?)`, 1, owner, now())
+	if err != nil {
+		// The primary key violation means that the lock is already taken.
+		var lockedBy string
+		var lockedAt time.Time
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if m.db.QueryRowContext(ctx, `SELECT owner, locked_at FROM schema_lock`).Scan(&lockedBy, &lockedAt) == nil {
+			return fmt.Errorf("%w (%s since %s), run 'migrate force' to release a stale lock", ErrLocked, lockedBy, lockedAt.Format(time.RFC3339))
+		}
+		return err
+	}
+
+	err = fn()
+
+	// A lock left behind blocks all the next runs, so the failure to release
+	// it is reported, unless fn has failed first.
+	_, unlockErr := m.exec(`DELETE FROM schema_lock WHERE id = ?`, 1)
+	if err == nil && unlockErr != nil {
+		err = fmt.Errorf("release the migration lock: %w", unlockErr)
+	}
+	return err
+}
+
+func (m *Migrator) ensureTables() error {
+	timestamp := "DATETIME"
+	if m.driver == "postgres" {
+		timestamp = "timestamp(0) with time zone"
+	}
+
+	_, err := m.exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			dirty BOOLEAN NOT NULL,
+			applied_at ` + timestamp + ` NOT NULL
+		)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.exec(`
+		CREATE TABLE IF NOT EXISTS schema_lock (
+			id INTEGER NOT NULL PRIMARY KEY,
+			owner VARCHAR(255) NOT NULL,
+			locked_at ` + timestamp + ` NOT NULL
+		)`)
+	return err
+}
+
+func (m *Migrator) exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return m.db.ExecContext(ctx, sqldialect.Rebind(m.driver, query), args...)
+}
+
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Splits the SQL script into statements by semicolons, which are not
+// inside quoted strings or comments.
+func splitStatements(script string) []string {
+	var statements []string
+	var sb strings.Builder
+
+	var quote rune
+	inComment := false
+
+	flush := func() {
+		stmt := strings.TrimSpace(sb.String())
+		if stmt != "" {
+			statements = append(statements, stmt)
+		}
+		sb.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inComment:
+			if r == '\n' {
+				inComment = false
+				sb.WriteRune(r)
+			}
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			inComment = true
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	flush()
+
+	return statements
+}
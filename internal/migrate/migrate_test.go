@@ -0,0 +1,245 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	_ "github.com/glebarez/go-sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"sqlite/000001_create_events.up.sql":   {Data: []byte("CREATE TABLE events (id INTEGER PRIMARY KEY, title TEXT);")},
+		"sqlite/000001_create_events.down.sql": {Data: []byte("DROP TABLE events;")},
+		"sqlite/000002_add_index.up.sql":       {Data: []byte("CREATE INDEX events_title_idx ON events (title);")},
+		"sqlite/000002_add_index.down.sql":     {Data: []byte("DROP INDEX events_title_idx;")},
+		"sqlite/README.md":                     {Data: []byte("Not a migration.")},
+	}
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+
+	m, err := New(db, "sqlite", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func versions(migrations []Migration) []int64 {
+	var vs []int64
+	for _, mig := range migrations {
+		vs = append(vs, mig.Version)
+	}
+	return vs
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr bool
+	}{
+		{"valid", testMigrations(), false},
+		{"no up script", fstest.MapFS{"sqlite/000001_a.down.sql": {Data: []byte("SELECT 1;")}}, true},
+		{"different names", fstest.MapFS{
+			"sqlite/000001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"sqlite/000001_b.down.sql": {Data: []byte("SELECT 1;")},
+		}, true},
+		{"no driver directory", fstest.MapFS{"mysql/000001_a.up.sql": {Data: []byte("SELECT 1;")}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(nil, "sqlite", tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v; want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMigrator(t, db, testMigrations())
+
+	done, err := m.Up(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("Up(1) applied %v; want [1]", got)
+	}
+
+	done, err = m.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{2}) {
+		t.Fatalf("Up(0) applied %v; want [2]", got)
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("Pending() = %d; want 0", pending)
+	}
+
+	done, err = m.Down(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{2, 1}) {
+		t.Fatalf("Down(2) reverted %v; want [2 1]", got)
+	}
+
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'events'`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("the events table is left after Down")
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := newTestMigrator(t, db, testMigrations()).Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := testMigrations()
+	fsys["sqlite/000001_create_events.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE events (id INTEGER PRIMARY KEY);")}
+	m := newTestMigrator(t, db, fsys)
+
+	_, err = m.Up(0)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Up() error = %v; want %v", err, ErrChecksumMismatch)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Modified || statuses[1].Modified {
+		t.Errorf("Status() = %+v; want only the first migration modified", statuses)
+	}
+
+	// Force accepts the modified file.
+	err = m.Force(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up(0)
+	if err != nil {
+		t.Errorf("Up() after Force error = %v", err)
+	}
+}
+
+func TestDirty(t *testing.T) {
+	db := newTestDB(t)
+
+	fsys := testMigrations()
+	fsys["sqlite/000002_add_index.up.sql"] = &fstest.MapFile{Data: []byte("CREATE INDEX events_title_idx ON no_such_table (title);")}
+	m := newTestMigrator(t, db, fsys)
+
+	done, err := m.Up(0)
+	if err == nil {
+		t.Fatal("Up() with a broken migration succeeded")
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("Up() applied %v; want [1]", got)
+	}
+
+	_, err = m.Up(0)
+	if !errors.Is(err, ErrDirty) {
+		t.Fatalf("Up() error = %v; want %v", err, ErrDirty)
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 1 {
+		t.Errorf("Pending() = %d; want 1 (the dirty migration)", pending)
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMigrator(t, db, testMigrations())
+
+	// Another process holds the lock.
+	err := m.ensureTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.exec(`INSERT INTO schema_lock (id, owner, locked_at) VALUES (?, ?, ?)`, 1, "other:1", now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Up(0)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Up() error = %v; want %v", err, ErrLocked)
+	}
+
+	// Force releases the stale lock.
+	err = m.Force(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lock is released after the run.
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM schema_lock`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d lock rows are left after Up", n)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"INSERT INTO a VALUES ('x;y');", []string{"INSERT INTO a VALUES ('x;y')"}},
+		{"-- Drop it; really.\nDROP TABLE a;", []string{"DROP TABLE a"}},
+		{"SELECT \"a;b\" FROM `c;d`", []string{"SELECT \"a;b\" FROM `c;d`"}},
+		{" ; \n ;", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitStatements(%q) = %q; want %q", tt.script, got, tt.want)
+		}
+	}
+}
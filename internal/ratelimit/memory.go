@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps a token bucket per key in the process: the bucket holds
+// up to Limit tokens, and is refilled at Limit tokens per Window. Each instance
+// of the application counts the requests on its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	stop    chan struct{}
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+	full     time.Time // When the bucket is refilled, and the same as a missing one.
+}
+
+// NewMemoryStore returns the store, which removes the refilled buckets every
+// cleanupInterval. So the map holds only the clients, which made requests
+// within the window of their policy.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		buckets: make(map[string]*bucket),
+		stop:    make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.deleteExpired()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *MemoryStore) Allow(policy Policy, key string) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rate := float64(policy.Limit) / policy.Window.Seconds() // Tokens per second.
+
+	key = policy.Name + ":" + key
+	b, found := s.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(policy.Limit), lastSeen: now}
+		s.buckets[key] = b
+	}
+
+	// Refill the tokens for the time passed since the last request.
+	b.tokens = math.Min(float64(policy.Limit), b.tokens+now.Sub(b.lastSeen).Seconds()*rate)
+	b.lastSeen = now
+
+	result := Result{Limit: policy.Limit}
+
+	if b.tokens < 1 {
+		result.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return result, nil
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(policy.Limit) - b.tokens) / rate * float64(time.Second)))
+	result.Allowed = true
+	result.Remaining = int(b.tokens)
+	return result, nil
+}
+
+func (s *MemoryStore) Peek(policy Policy, key string) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := Result{Allowed: true, Limit: policy.Limit, Remaining: policy.Limit}
+
+	b, found := s.buckets[policy.Name+":"+key]
+	if !found {
+		return result, nil
+	}
+
+	rate := float64(policy.Limit) / policy.Window.Seconds()
+	tokens := math.Min(float64(policy.Limit), b.tokens+time.Since(b.lastSeen).Seconds()*rate)
+
+	if tokens < 1 {
+		result.Allowed = false
+		result.Remaining = 0
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+		return result, nil
+	}
+
+	result.Remaining = int(tokens)
+	return result, nil
+}
+
+// StopCleanup stops the background cleanup of the refilled buckets.
+func (s *MemoryStore) StopCleanup() {
+	close(s.stop)
+}
+
+func (s *MemoryStore) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
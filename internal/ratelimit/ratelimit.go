@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Window for each key (client).
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Parses the policy from "name=LIMIT/WINDOW", e.g. "auth=10/1m".
+func ParsePolicy(s string) (Policy, error) {
+	name, spec := "", s
+	if i := strings.Index(s, "="); i != -1 {
+		name, spec = s[:i], s[i+1:]
+	}
+
+	i := strings.Index(spec, "/")
+	if name == "" || i == -1 {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected name=LIMIT/WINDOW", s)
+	}
+
+	limit, err := strconv.Atoi(spec[:i])
+	if err != nil || limit < 1 {
+		return Policy{}, fmt.Errorf("invalid limit in rate limit policy %q", s)
+	}
+
+	window, err := time.ParseDuration(spec[i+1:])
+	if err != nil || window < time.Millisecond {
+		return Policy{}, fmt.Errorf("invalid window in rate limit policy %q", s)
+	}
+
+	return Policy{Name: name, Limit: limit, Window: window}, nil
+}
+
+// Result of a request check, used for the RateLimit-* response headers.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Set when the request isn't allowed.
+}
+
+// Store counts the requests of the clients.
+type Store interface {
+	// Allow counts the request of the key under the policy, if it is allowed.
+	Allow(policy Policy, key string) (Result, error)
+	// Peek returns the state of the key under the policy without counting a request.
+	Peek(policy Policy, key string) (Result, error)
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Policy
+		wantErr bool
+	}{
+		{"auth=10/1m", Policy{Name: "auth", Limit: 10, Window: time.Minute}, false},
+		{"user=20/2s", Policy{Name: "user", Limit: 20, Window: 2 * time.Second}, false},
+		{"fast=1/1ms", Policy{Name: "fast", Limit: 1, Window: time.Millisecond}, false},
+		{"10/1m", Policy{}, true},
+		{"auth=10", Policy{}, true},
+		{"auth=0/1m", Policy{}, true},
+		{"auth=x/1m", Policy{}, true},
+		{"auth=10/1y", Policy{}, true},
+		{"auth=10/500us", Policy{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v; want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %+v; want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ol-ilyassov/test/internal/sqldialect"
+	"math"
+	"time"
+)
+
+// SQLStore counts the requests in the "rate_limits" table (see the migrations),
+// so the limits are shared by all instances of the application.
+//
+// It implements the sliding window counter: the requests are counted in fixed
+// windows, and the count of the previous window is weighted by its part, which
+// still overlaps the sliding window.
+type SQLStore struct {
+	db     *sql.DB
+	driver string // mysql|postgres|sqlite
+	stop   chan struct{}
+}
+
+// NewSQLStore returns the store, which removes the outdated counters every cleanupInterval.
+func NewSQLStore(db *sql.DB, driver string, cleanupInterval time.Duration) *SQLStore {
+	s := &SQLStore{
+		db:     db,
+		driver: driver,
+		stop:   make(chan struct{}),
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.deleteExpired()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *SQLStore) Allow(policy Policy, key string) (Result, error) {
+	now := time.Now()
+	window := policy.Window.Milliseconds()
+	start := now.UnixNano() / int64(time.Millisecond) / window * window // Of the current window, in ms.
+
+	key = policy.Name + ":" + key
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// The upsert locks the counter of the current window until the commit, so
+	// the concurrent requests of the key (from all instances) are decided one
+	// after another, and none of them is admitted over the limit.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO rate_limits (bucket, window_start, hits, expires_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (bucket, window_start) DO UPDATE SET hits = rate_limits.hits + 1`
+
+	if s.driver == "mysql" {
+		query = `
+			INSERT INTO rate_limits (bucket, window_start, hits, expires_at)
+			VALUES (?, ?, 1, ?)
+			ON DUPLICATE KEY UPDATE hits = hits + 1`
+	}
+
+	// The counter is needed for the current and the next window.
+	_, err = tx.ExecContext(ctx, sqldialect.Rebind(s.driver, query), key, start, start+2*window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	previous, current, err := s.hits(ctx, tx, key, start, window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Part of the current window, which has passed.
+	elapsed := float64(now.UnixNano()/int64(time.Millisecond)-start) / float64(window)
+	estimate := float64(previous)*(1-elapsed) + float64(current)
+
+	result := Result{Limit: policy.Limit}
+
+	if estimate > float64(policy.Limit) {
+		// Don't count the rejected request.
+		query := `
+			UPDATE rate_limits
+			SET hits = hits - 1
+			WHERE bucket = ? AND window_start = ?`
+
+		_, err = tx.ExecContext(ctx, sqldialect.Rebind(s.driver, query), key, start)
+		if err != nil {
+			return Result{}, err
+		}
+
+		result.RetryAfter = retryAfter(policy, previous, current-1, elapsed)
+	} else {
+		result.Allowed = true
+		result.Remaining = int(math.Floor(float64(policy.Limit) - estimate))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
+
+func (s *SQLStore) Peek(policy Policy, key string) (Result, error) {
+	now := time.Now()
+	window := policy.Window.Milliseconds()
+	start := now.UnixNano() / int64(time.Millisecond) / window * window
+
+	key = policy.Name + ":" + key
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	previous, current, err := s.hits(ctx, s.db, key, start, window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	elapsed := float64(now.UnixNano()/int64(time.Millisecond)-start) / float64(window)
+	estimate := float64(previous)*(1-elapsed) + float64(current)
+
+	result := Result{Limit: policy.Limit}
+
+	// One more request would be rejected.
+	if estimate+1 > float64(policy.Limit) {
+		result.RetryAfter = retryAfter(policy, previous, current, elapsed)
+		return result, nil
+	}
+
+	result.Allowed = true
+	result.Remaining = int(math.Floor(float64(policy.Limit) - estimate))
+	return result, nil
+}
+
+// Implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Returns the number of requests counted in the previous and the current window.
+func (s *SQLStore) hits(ctx context.Context, q queryer, key string, start, window int64) (previous, current int, err error) {
+	query := `
+		SELECT window_start, hits
+		FROM rate_limits
+		WHERE bucket = ? AND window_start IN (?, ?)`
+
+	rows, err := q.QueryContext(ctx, sqldialect.Rebind(s.driver, query), key, start-window, start)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var windowStart int64
+		var hits int
+
+		err = rows.Scan(&windowStart, &hits)
+		if err != nil {
+			return 0, 0, err
+		}
+		if windowStart == start {
+			current = hits
+		} else {
+			previous = hits
+		}
+	}
+	return previous, current, rows.Err()
+}
+
+// Time until one more request fits into the sliding window, with the given counts
+// of the previous and current windows, and the passed part of the current one.
+func retryAfter(policy Policy, previous, current int, elapsed float64) time.Duration {
+	limit := float64(policy.Limit - 1)
+
+	var at float64 // Time from the start of the current window, in windows.
+	switch {
+	case float64(current) > limit:
+		// Wait for the next window, until the weight of the current one drops enough.
+		at = 1 + (1 - limit/float64(current))
+	case previous > 0:
+		at = 1 - (limit-float64(current))/float64(previous)
+	}
+
+	wait := (at - elapsed) * float64(policy.Window)
+	if wait < float64(time.Second) {
+		wait = float64(time.Second)
+	}
+	return time.Duration(wait)
+}
+
+// StopCleanup stops the background cleanup of the outdated counters.
+func (s *SQLStore) StopCleanup() {
+	close(s.stop)
+}
+
+func (s *SQLStore) deleteExpired() error {
+	query := `
+		DELETE FROM rate_limits
+		WHERE expires_at < ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, sqldialect.Rebind(s.driver, query), time.Now().UnixNano()/int64(time.Millisecond))
+	return err
+}
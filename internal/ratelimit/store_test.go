@@ -0,0 +1,253 @@
+package ratelimit
+
+import (
+	"database/sql"
+	_ "github.com/glebarez/go-sqlite"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestSQLStore(t *testing.T) *SQLStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Like the application, a single writer.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		CREATE TABLE rate_limits (
+			bucket TEXT NOT NULL,
+			window_start INTEGER NOT NULL,
+			hits INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL,
+			PRIMARY KEY (bucket, window_start)
+		)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSQLStore(db, "sqlite", time.Minute)
+	t.Cleanup(s.StopCleanup)
+	return s
+}
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	s := NewMemoryStore(time.Minute)
+	t.Cleanup(s.StopCleanup)
+	return s
+}
+
+var testStores = []struct {
+	name  string
+	store func(t *testing.T) Store
+}{
+	{"memory", func(t *testing.T) Store { return newTestMemoryStore(t) }},
+	{"sql", func(t *testing.T) Store { return newTestSQLStore(t) }},
+}
+
+func TestAllow(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 3, Window: time.Hour}
+
+	for _, st := range testStores {
+		t.Run(st.name, func(t *testing.T) {
+			store := st.store(t)
+
+			for i := 0; i < policy.Limit; i++ {
+				result, err := store.Allow(policy, "1.2.3.4")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed || result.Limit != policy.Limit || result.Remaining != policy.Limit-1-i {
+					t.Fatalf("request %d: got %+v; want allowed with %d remaining", i+1, result, policy.Limit-1-i)
+				}
+			}
+
+			result, err := store.Allow(policy, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed || result.Remaining != 0 || result.RetryAfter <= 0 {
+				t.Errorf("over the limit: got %+v; want rejected with a retry after", result)
+			}
+
+			// The rejected request isn't counted, Peek doesn't count at all.
+			peek, err := store.Peek(policy, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if peek.Allowed {
+				t.Errorf("Peek() = %+v; want not allowed", peek)
+			}
+
+			// The keys and the policies are counted separately.
+			result, err = store.Allow(policy, "5.6.7.8")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Allowed {
+				t.Error("another key is limited")
+			}
+			result, err = store.Allow(Policy{Name: "other", Limit: 1, Window: time.Hour}, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Allowed {
+				t.Error("another policy is limited")
+			}
+		})
+	}
+}
+
+func TestPeek(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 5, Window: time.Hour}
+
+	for _, st := range testStores {
+		t.Run(st.name, func(t *testing.T) {
+			store := st.store(t)
+
+			for i := 0; i < 3; i++ {
+				result, err := store.Peek(policy, "1.2.3.4")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !result.Allowed || result.Remaining != policy.Limit {
+					t.Fatalf("Peek() = %+v; want allowed with %d remaining", result, policy.Limit)
+				}
+			}
+
+			_, err := store.Allow(policy, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := store.Peek(policy, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Remaining != policy.Limit-1 {
+				t.Errorf("Peek() after Allow = %+v; want %d remaining", result, policy.Limit-1)
+			}
+		})
+	}
+}
+
+func TestAllowRefill(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 2, Window: 100 * time.Millisecond}
+
+	for _, st := range testStores {
+		t.Run(st.name, func(t *testing.T) {
+			store := st.store(t)
+
+			for i := 0; i <= policy.Limit; i++ {
+				_, err := store.Allow(policy, "1.2.3.4")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// After two windows nothing is left of the counted requests.
+			time.Sleep(2 * policy.Window)
+
+			result, err := store.Allow(policy, "1.2.3.4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Allowed {
+				t.Errorf("got %+v after the window; want allowed", result)
+			}
+		})
+	}
+}
+
+func TestAllowConcurrent(t *testing.T) {
+	policy := Policy{Name: "test", Limit: 10, Window: time.Hour}
+
+	for _, st := range testStores {
+		t.Run(st.name, func(t *testing.T) {
+			store := st.store(t)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			allowed := 0
+
+			for i := 0; i < 3*policy.Limit; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					result, err := store.Allow(policy, "1.2.3.4")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if result.Allowed {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if allowed != policy.Limit {
+				t.Errorf("%d concurrent requests allowed; want %d", allowed, policy.Limit)
+			}
+		})
+	}
+}
+
+func TestSQLStoreDeleteExpired(t *testing.T) {
+	store := newTestSQLStore(t)
+	policy := Policy{Name: "test", Limit: 1, Window: time.Millisecond}
+
+	_, err := store.Allow(policy, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	err = store.deleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = store.db.QueryRow(`SELECT COUNT(*) FROM rate_limits`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d counters left after the cleanup; want 0", n)
+	}
+}
+
+func TestMemoryStoreDeleteExpired(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	// The 100/s bucket refills the token in 10ms, the 1/h one doesn't.
+	policy := Policy{Name: "test", Limit: 100, Window: time.Second}
+	_, err := store.Allow(policy, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Allow(Policy{Name: "daily", Limit: 1, Window: time.Hour}, "1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	store.deleteExpired()
+
+	if _, found := store.buckets["test:1.2.3.4"]; found {
+		t.Error("the refilled bucket is kept")
+	}
+	if _, found := store.buckets["daily:1.2.3.4"]; !found {
+		t.Error("the bucket, which isn't refilled, is removed")
+	}
+}
@@ -0,0 +1,141 @@
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver returns the IP address of the client, which sent the request.
+// The forwarding headers (Forwarded, X-Forwarded-For, X-Real-IP) are honored only
+// when the request comes from a trusted proxy, otherwise anyone could spoof them.
+type Resolver struct {
+	trusted Networks
+}
+
+// New returns the resolver, which trusts the proxies in the given CIDRs.
+// Single IP addresses are accepted too.
+func New(trustedProxies []string) (*Resolver, error) {
+	trusted, err := ParseNetworks(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &Resolver{trusted: trusted}, nil
+}
+
+// Networks is a set of IP networks, e.g. the trusted proxies or the clients
+// allowed to read the metrics.
+type Networks []*net.IPNet
+
+// ParseNetworks parses the CIDRs. Single IP addresses are accepted too.
+func ParseNetworks(cidrs []string) (Networks, error) {
+	var networks Networks
+
+	for _, s := range cidrs {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR %q", s)
+		}
+		networks = append(networks, ipNet)
+	}
+
+	return networks, nil
+}
+
+// Contains reports whether the IP address (with an optional port) is in one of
+// the networks.
+func (networks Networks) Contains(s string) bool {
+	ip := parseIP(s)
+	return ip != nil && networks.contains(ip)
+}
+
+func (networks Networks) contains(ip net.IP) bool {
+	for _, ipNet := range networks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client IP address of the request. The forwarding chain is
+// walked from the nearest hop, skipping the trusted proxies: the first untrusted
+// address is the client. RemoteAddr without a port is accepted.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remote := parseIP(r.RemoteAddr)
+	if remote == nil {
+		return r.RemoteAddr
+	}
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+
+	// Hops from the client to the nearest proxy.
+	var hops []string
+	switch {
+	case len(r.Header.Values("Forwarded")) != 0:
+		hops = forwardedFor(strings.Join(r.Header.Values("Forwarded"), ","))
+	case len(r.Header.Values("X-Forwarded-For")) != 0:
+		hops = strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	case r.Header.Get("X-Real-IP") != "":
+		hops = []string{r.Header.Get("X-Real-IP")}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Garbage in the chain, the last known hop is the best guess.
+			break
+		}
+		client = ip
+		if !res.isTrusted(ip) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+func (res *Resolver) isTrusted(ip net.IP) bool {
+	return res.trusted.contains(ip)
+}
+
+// Returns the "for" values of the Forwarded header (RFC 7239), e.g.
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+func forwardedFor(header string) []string {
+	var hops []string
+
+	for _, element := range strings.Split(header, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			pair = strings.TrimSpace(pair)
+			if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+				hops = append(hops, strings.Trim(pair[4:], `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// Parses the IP address with an optional port ("1.2.3.4", "1.2.3.4:80",
+// "::1", "[::1]:80"). Returns nil for obfuscated ("unknown", "_hidden") values.
+func parseIP(s string) net.IP {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(strings.Trim(s, "[]"))
+}
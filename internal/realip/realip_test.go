@@ -0,0 +1,114 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"10.0.0.0/8", "192.168.1.1", "::1", "fd00::/8"}, false},
+		{[]string{"10.0.0.0/33"}, true},
+		{[]string{"proxy.local"}, true},
+	}
+
+	for _, tt := range tests {
+		_, err := New(tt.proxies)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q) error = %v; want error %v", tt.proxies, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	res, err := New([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"no port", "203.0.113.7", nil, "203.0.113.7"},
+		{"ipv6", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+		{"unparsable remote", "@", nil, "@"},
+		{"untrusted proxy is spoofing", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without headers", "10.0.0.1:5000", nil, "10.0.0.1"},
+		// The client prepends a fake address, the first untrusted hop from the right wins.
+		{"spoofed chain", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "192.168.1.1, 10.0.0.2"}, "192.168.1.1"},
+		{"garbage in the chain", "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "198.51.100.1, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"forwarded", "10.0.0.1:5000", map[string]string{"Forwarded": `for=198.51.100.1;proto=https, for="10.0.0.2"`}, "198.51.100.1"},
+		{"forwarded ipv6 with port", "[::1]:5000", map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"forwarded wins over x-forwarded-for", "10.0.0.1:5000", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "198.51.100.2"}, "198.51.100.1"},
+		{"x-real-ip", "192.168.1.1:5000", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if got := res.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPMultipleHeaders(t *testing.T) {
+	res, err := New([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The headers of the proxies are joined in order.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Add("X-Forwarded-For", "198.51.100.1")
+	r.Header.Add("X-Forwarded-For", "198.51.100.2, 10.0.0.2")
+
+	if got := res.ClientIP(r); got != "198.51.100.2" {
+		t.Errorf("ClientIP() = %q; want 198.51.100.2", got)
+	}
+}
+
+func TestNetworksContains(t *testing.T) {
+	networks, err := ParseNetworks([]string{"127.0.0.0/8", "::1", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"127.1.2.3:5000", true},
+		{"[::1]:5000", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"203.0.113.7", false},
+		{"", false},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		if got := networks.Contains(tt.ip); got != tt.want {
+			t.Errorf("Contains(%q) = %v; want %v", tt.ip, got, tt.want)
+		}
+	}
+}
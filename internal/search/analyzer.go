@@ -0,0 +1,136 @@
+package search
+
+import (
+	"github.com/kljensen/snowball/english"
+	"github.com/kljensen/snowball/russian"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a single word of the analyzed text.
+type token struct {
+	terms      []string // Normalized forms of the word (one or more stems).
+	start, end int      // Byte offsets of the word in the original text.
+}
+
+// Letters, which exist in the Kazakh alphabet, but not in the Russian one.
+const kazakhLetters = "әғқңөұүһі"
+
+// Split the text into words, and normalize each of them to the stems used by the index.
+func analyze(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			tokens = append(tokens, token{terms: stems(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{terms: stems(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// Returns the stems of a word, depending on its language:
+//   - Latin words are stemmed with the English Snowball stemmer;
+//   - Cyrillic words with Kazakh-specific letters are stemmed with the Kazakh stemmer;
+//   - other Cyrillic words could be either Russian or Kazakh, so both stems are returned.
+func stems(word string) []string {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+
+	switch {
+	case isLatin(word):
+		return []string{english.Stem(word, true)}
+	case isCyrillic(word):
+		if strings.ContainsAny(word, kazakhLetters) {
+			return []string{kazakhStem(word)}
+		}
+		ru, kk := russian.Stem(word, true), kazakhStem(word)
+		if ru == kk {
+			return []string{ru}
+		}
+		return []string{ru, kk}
+	default:
+		return []string{word}
+	}
+}
+
+func isLatin(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Latin, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Common Kazakh inflectional suffixes: plural, possessive, case and verb endings.
+var kazakhSuffixes = func() []string {
+	suffixes := []string{
+		// Plural.
+		"лар", "лер", "дар", "дер", "тар", "тер",
+		// Possessive.
+		"ымыз", "іміз", "ыңыз", "іңіз", "сы", "сі", "ым", "ім", "ың", "ің",
+		// Genitive.
+		"ның", "нің", "дың", "дің", "тың", "тің",
+		// Dative.
+		"ға", "ге", "қа", "ке", "на", "не",
+		// Accusative.
+		"ны", "ні", "ды", "ді", "ты", "ті",
+		// Locative.
+		"нда", "нде", "да", "де", "та", "те",
+		// Ablative.
+		"нан", "нен", "дан", "ден", "тан", "тен",
+		// Instrumental.
+		"мен", "бен", "пен",
+		// Participles and adjectives.
+		"ған", "ген", "қан", "кен", "атын", "етін", "йтын", "йтін",
+		"лық", "лік", "дық", "дік", "тық", "тік", "сыз", "сіз", "шы", "ші",
+	}
+	// Longest suffixes are tried first.
+	sort.SliceStable(suffixes, func(i, j int) bool {
+		return utf8.RuneCountInString(suffixes[i]) > utf8.RuneCountInString(suffixes[j])
+	})
+	return suffixes
+}()
+
+// Light Kazakh stemmer: agglutinative suffixes are stripped one by one from the
+// end of the word, while the remaining stem is at least 3 letters long.
+func kazakhStem(word string) string {
+	for {
+		stripped := false
+		for _, suffix := range kazakhSuffixes {
+			if !strings.HasSuffix(word, suffix) {
+				continue
+			}
+			stem := strings.TrimSuffix(word, suffix)
+			if utf8.RuneCountInString(stem) >= 3 {
+				word = stem
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			return word
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKazakhStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"мектептерде", "мектеп"},
+		{"кітаптарымыз", "кітап"},
+		{"қаланың", "қала"},
+		{"баламен", "бала"},
+		{"ойындар", "ойын"},
+		// The stem is kept at least 3 letters long.
+		{"ада", "ада"},
+		{"үйлер", "үйлер"},
+		{"жол", "жол"},
+	}
+
+	for _, tt := range tests {
+		if got := kazakhStem(tt.word); got != tt.want {
+			t.Errorf("kazakhStem(%q) = %q; want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestStems(t *testing.T) {
+	tests := []struct {
+		word string
+		want []string
+	}{
+		// English.
+		{"Running", []string{"run"}},
+		{"concerts", []string{"concert"}},
+		// Kazakh-specific letters: only the Kazakh stem.
+		{"Қаланың", []string{"қала"}},
+		// Common Cyrillic: both the Russian and the Kazakh stems.
+		{"концертте", []string{"концертт", "концерт"}},
+		{"книги", []string{"книг", "книги"}},
+		// The same stems are not repeated.
+		{"дом", []string{"дом"}},
+		// "ё" is folded to "е".
+		{"ёлка", []string{"елк", "елка"}},
+		// Digits and other scripts are kept as is.
+		{"2024", []string{"2024"}},
+	}
+
+	for _, tt := range tests {
+		if got := stems(tt.word); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("stems(%q) = %q; want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	text := "Jazz — концерт, 2024!"
+	tokens := analyze(text)
+
+	want := []string{"Jazz", "концерт", "2024"}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens; want %d", len(tokens), len(want))
+	}
+	for i, tok := range tokens {
+		if got := text[tok.start:tok.end]; got != want[i] {
+			t.Errorf("token %d = %q; want %q", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 ranking parameters.
+const (
+	k1          = 1.2
+	b           = 0.75
+	titleWeight = 2 // Title matches are counted twice.
+)
+
+// Document is a searchable item: an event with its title and description.
+type Document struct {
+	ID    int64
+	Title string
+	Body  string
+}
+
+// Result is a single search hit. Title and Snippet are HTML-escaped,
+// with the matched words wrapped in <mark> tags.
+type Result struct {
+	ID      int64   `json:"id"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+}
+
+type posting struct {
+	title int // Term frequency in the title.
+	body  int // Term frequency in the body.
+}
+
+type indexedDocument struct {
+	Document
+	length int      // Weighted number of words.
+	terms  []string // Unique terms, used to remove the document from the index.
+}
+
+// Index is a thread-safe in-memory inverted index with BM25 ranking.
+type Index struct {
+	mu          sync.RWMutex
+	docs        map[int64]*indexedDocument
+	postings    map[string]map[int64]*posting
+	totalLength int
+}
+
+func New() *Index {
+	return &Index{
+		docs:     make(map[int64]*indexedDocument),
+		postings: make(map[string]map[int64]*posting),
+	}
+}
+
+// Len returns the number of indexed documents.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Add the document to the index, replacing the previous version with the same ID.
+func (idx *Index) Add(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(doc.ID)
+
+	d := &indexedDocument{Document: doc}
+	seen := make(map[string]bool)
+
+	add := func(text string, inTitle bool) int {
+		tokens := analyze(text)
+		for _, t := range tokens {
+			for _, term := range t.terms {
+				docs, ok := idx.postings[term]
+				if !ok {
+					docs = make(map[int64]*posting)
+					idx.postings[term] = docs
+				}
+				p, ok := docs[doc.ID]
+				if !ok {
+					p = &posting{}
+					docs[doc.ID] = p
+				}
+				if inTitle {
+					p.title++
+				} else {
+					p.body++
+				}
+				if !seen[term] {
+					seen[term] = true
+					d.terms = append(d.terms, term)
+				}
+			}
+		}
+		return len(tokens)
+	}
+
+	d.length = titleWeight*add(doc.Title, true) + add(doc.Body, false)
+
+	idx.docs[doc.ID] = d
+	idx.totalLength += d.length
+}
+
+// Remove the document with the given ID from the index.
+func (idx *Index) Remove(id int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id int64) {
+	d, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range d.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLength -= d.length
+	delete(idx.docs, id)
+}
+
+// Search returns up to limit documents, which contain any of the query words,
+// ordered by relevance.
+func (idx *Index) Search(query string, limit int) []*Result {
+	words := analyze(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(words) == 0 || len(idx.docs) == 0 {
+		return []*Result{}
+	}
+
+	avgLength := float64(idx.totalLength) / float64(len(idx.docs))
+	scores := make(map[int64]float64)
+	matched := make(map[string]bool)
+
+	for _, word := range words {
+		// Alternative stems of a single word are not summed up, the best one is used.
+		best := make(map[int64]float64)
+		for _, term := range word.terms {
+			docs := idx.postings[term]
+			if len(docs) == 0 {
+				continue
+			}
+			matched[term] = true
+
+			n := float64(len(docs))
+			idf := math.Log(1 + (float64(len(idx.docs))-n+0.5)/(n+0.5))
+
+			for id, p := range docs {
+				tf := float64(titleWeight*p.title + p.body)
+				norm := k1 * (1 - b + b*float64(idx.docs[id].length)/avgLength)
+				score := idf * tf * (k1 + 1) / (tf + norm)
+				if score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+		}
+	}
+
+	results := make([]*Result, 0, len(scores))
+	for id, score := range scores {
+		d := idx.docs[id]
+		results = append(results, &Result{
+			ID:      id,
+			Score:   math.Round(score*1000) / 1000,
+			Title:   highlight(d.Title, matched, 0),
+			Snippet: highlight(d.Body, matched, 30),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Returns the HTML-escaped text with the matched words wrapped in <mark> tags.
+// If maxWords is positive, only a fragment around the first match is returned.
+func highlight(text string, matched map[string]bool, maxWords int) string {
+	tokens := analyze(text)
+
+	first, last := 0, len(tokens)
+	if maxWords > 0 && len(tokens) > maxWords {
+		for i, t := range tokens {
+			if isMatch(t, matched) {
+				// Keep a few words of context before the first match.
+				first = i - maxWords/5
+				break
+			}
+		}
+		if first < 0 {
+			first = 0
+		}
+		if first+maxWords > len(tokens) {
+			first = len(tokens) - maxWords
+		}
+		last = first + maxWords
+	}
+
+	var sb strings.Builder
+
+	pos := 0
+	if first > 0 {
+		sb.WriteString("… ")
+		pos = tokens[first].start
+	}
+	for _, t := range tokens[first:last] {
+		if !isMatch(t, matched) {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:t.start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[t.start:t.end]))
+		sb.WriteString("</mark>")
+		pos = t.end
+	}
+	if last < len(tokens) {
+		sb.WriteString(html.EscapeString(text[pos:tokens[last-1].end]))
+		sb.WriteString(" …")
+	} else {
+		sb.WriteString(html.EscapeString(text[pos:]))
+	}
+	return sb.String()
+}
+
+func isMatch(t token, matched map[string]bool) bool {
+	for _, term := range t.terms {
+		if matched[term] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	idx := New()
+	idx.Add(Document{ID: 1, Title: "Jazz concert", Body: "An evening of jazz music in the park."})
+	idx.Add(Document{ID: 2, Title: "Book fair", Body: "Meet the authors. Live jazz in the afternoon."})
+	idx.Add(Document{ID: 3, Title: "Мектептегі концерт", Body: "Балалар концерті."})
+	idx.Add(Document{ID: 4, Title: "Chess tournament", Body: "Open for everyone."})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int64
+	}{
+		{"title match ranks first", "jazz", []int64{1, 2}},
+		{"stemmed", "concerts", []int64{1}},
+		{"kazakh inflections", "концерттер", []int64{3}},
+		{"any of the words", "chess fair", []int64{4, 2}},
+		{"no match", "opera", []int64{}},
+		{"empty query", " , ", []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := idx.Search(tt.query, 10)
+
+			var got []int64
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v; want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v; want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchScores(t *testing.T) {
+	idx := New()
+	idx.Add(Document{ID: 1, Title: "Jazz", Body: "jazz jazz"})
+	idx.Add(Document{ID: 2, Title: "Concert", Body: "jazz"})
+	idx.Add(Document{ID: 3, Title: "Concert", Body: "rock"})
+
+	results := idx.Search("jazz", 0)
+	if len(results) != 2 {
+		t.Fatalf("got %d results; want 2", len(results))
+	}
+	if results[0].ID != 1 || results[0].Score <= results[1].Score {
+		t.Errorf("the document with more (and title) matches must rank higher: %+v, %+v", results[0], results[1])
+	}
+
+	if results := idx.Search("jazz", 1); len(results) != 1 {
+		t.Errorf("got %d results; want the limit of 1", len(results))
+	}
+}
+
+func TestAddReplacesAndRemove(t *testing.T) {
+	idx := New()
+	idx.Add(Document{ID: 1, Title: "Jazz concert"})
+	idx.Add(Document{ID: 1, Title: "Rock concert"})
+
+	if n := idx.Len(); n != 1 {
+		t.Fatalf("Len() = %d; want 1", n)
+	}
+	if results := idx.Search("jazz", 10); len(results) != 0 {
+		t.Errorf("the replaced version is still found: %+v", results[0])
+	}
+	if results := idx.Search("rock", 10); len(results) != 1 {
+		t.Errorf("got %d results for the new version; want 1", len(results))
+	}
+
+	idx.Remove(1)
+	if n := idx.Len(); n != 0 {
+		t.Errorf("Len() = %d after Remove; want 0", n)
+	}
+	if len(idx.postings) != 0 || idx.totalLength != 0 {
+		t.Errorf("postings and the total length are left after Remove: %d, %d", len(idx.postings), idx.totalLength)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	idx := New()
+	idx.Add(Document{ID: 1, Title: "Jazz & <blues>", Body: "Live jazz tonight."})
+
+	results := idx.Search("jazz", 10)
+	if len(results) != 1 {
+		t.Fatalf("got %d results; want 1", len(results))
+	}
+
+	if want := "<mark>Jazz</mark> &amp; &lt;blues&gt;"; results[0].Title != want {
+		t.Errorf("Title = %q; want %q", results[0].Title, want)
+	}
+	if want := "Live <mark>jazz</mark> tonight."; results[0].Snippet != want {
+		t.Errorf("Snippet = %q; want %q", results[0].Snippet, want)
+	}
+}
@@ -0,0 +1,419 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+	"sync"
+	"time"
+)
+
+func init() {
+	// Basic types are registered by gob itself.
+	gob.Register(time.Time{})
+}
+
+type contextKey string
+
+const sessionContextKey = contextKey("session")
+
+type status int
+
+const (
+	unmodified status = iota
+	modified
+	destroyed
+)
+
+// Cookie holds the attributes of the session cookie.
+type Cookie struct {
+	Name     string
+	Domain   string
+	Path     string
+	HttpOnly bool
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Manager loads the session of the request from the store, and commits it
+// back before the response is written. Only the random session token is kept
+// in the cookie, the values stay on the server.
+type Manager struct {
+	// Session expires after IdleTimeout without requests (0 disables it), and
+	// after Lifetime since it was created, regardless of the activity.
+	IdleTimeout time.Duration
+	Lifetime    time.Duration
+
+	Cookie Cookie
+	Store  Store
+
+	// Called when the session can't be loaded or committed. The default one
+	// responds with a plain 500 Internal Server Error.
+	ErrorFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+func New(store Store) *Manager {
+	return &Manager{
+		Lifetime: 12 * time.Hour,
+		Cookie: Cookie{
+			Name:     "session",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		Store: store,
+		ErrorFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		},
+	}
+}
+
+// Session data of a single request.
+type sessionData struct {
+	mu       sync.Mutex
+	token    string
+	deadline time.Time // Absolute expiry.
+	values   map[string]interface{}
+	status   status
+}
+
+// Serialized form of the session in the store.
+type record struct {
+	Deadline time.Time
+	Values   map[string]interface{}
+}
+
+// Enable is a middleware, which makes the session available to the handlers.
+// The session, which is already loaded by Load, is reused.
+func (m *Manager) Enable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := m.Load(r)
+		if err != nil {
+			m.ErrorFunc(w, r, err)
+			return
+		}
+		sd := m.data(r)
+
+		sw := &sessionWriter{ResponseWriter: w, request: r, manager: m, data: sd}
+		next.ServeHTTP(sw, r)
+
+		// Commit the session even if the handler wrote nothing.
+		if !sw.written {
+			sw.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// Load returns the request with its session in the context, so the values can
+// be read before Enable (e.g. by an outer middleware). Only Enable commits the
+// changes.
+func (m *Manager) Load(r *http.Request) (*http.Request, error) {
+	if _, ok := r.Context().Value(sessionContextKey).(*sessionData); ok {
+		return r, nil
+	}
+
+	var token string
+	if cookie, err := r.Cookie(m.Cookie.Name); err == nil {
+		token = cookie.Value
+	}
+
+	sd, err := m.load(token)
+	if err != nil {
+		return r, err
+	}
+	return r.WithContext(context.WithValue(r.Context(), sessionContextKey, sd)), nil
+}
+
+func (m *Manager) load(token string) (*sessionData, error) {
+	sd := &sessionData{
+		deadline: time.Now().Add(m.Lifetime),
+		values:   make(map[string]interface{}),
+	}
+	if token == "" {
+		return sd, nil
+	}
+
+	b, found, err := m.Store.Find(token)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return sd, nil
+	}
+
+	var rec record
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&rec)
+	if err != nil {
+		return nil, err
+	}
+	if !rec.Deadline.After(time.Now()) {
+		return sd, nil
+	}
+
+	sd.token = token
+	sd.deadline = rec.Deadline
+	if rec.Values != nil {
+		sd.values = rec.Values
+	}
+	return sd, nil
+}
+
+// Saves the session to the store and sets the cookie. Sessions without any
+// values are not saved, so anonymous visitors don't create store records.
+func (m *Manager) commit(w http.ResponseWriter, sd *sessionData) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.status == destroyed || (sd.token == "" && len(sd.values) == 0) {
+		if sd.status == destroyed {
+			m.writeCookie(w, "", time.Time{})
+		}
+		return nil
+	}
+
+	// Unmodified sessions are only saved to extend the idle timeout.
+	if sd.status == unmodified && m.IdleTimeout == 0 {
+		return nil
+	}
+
+	expiry := sd.deadline
+	if m.IdleTimeout > 0 {
+		if idle := time.Now().Add(m.IdleTimeout); idle.Before(expiry) {
+			expiry = idle
+		}
+	}
+
+	if sd.token == "" {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		sd.token = token
+	}
+
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(record{Deadline: sd.deadline, Values: sd.values})
+	if err != nil {
+		return err
+	}
+
+	err = m.Store.Commit(sd.token, buf.Bytes(), expiry)
+	if err != nil {
+		return err
+	}
+
+	m.writeCookie(w, sd.token, expiry)
+	return nil
+}
+
+// Sets the session cookie, or removes it if the token is empty.
+func (m *Manager) writeCookie(w http.ResponseWriter, token string, expiry time.Time) {
+	cookie := &http.Cookie{
+		Name:     m.Cookie.Name,
+		Value:    token,
+		Domain:   m.Cookie.Domain,
+		Path:     m.Cookie.Path,
+		HttpOnly: m.Cookie.HttpOnly,
+		Secure:   m.Cookie.Secure,
+		SameSite: m.Cookie.SameSite,
+	}
+
+	if token == "" {
+		cookie.Expires = time.Unix(1, 0)
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expiry.UTC()
+		cookie.MaxAge = int(time.Until(expiry).Seconds() + 1)
+	}
+
+	w.Header().Add("Set-Cookie", cookie.String())
+	w.Header().Add("Cache-Control", `no-cache="Set-Cookie"`)
+}
+
+// Put adds the value to the session, replacing the existing one.
+func (m *Manager) Put(r *http.Request, key string, val interface{}) {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.values[key] = val
+	sd.status = modified
+}
+
+// Get returns the value for the key, or nil if there is no such value.
+func (m *Manager) Get(r *http.Request, key string) interface{} {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	return sd.values[key]
+}
+
+// Pop returns the value for the key and removes it from the session.
+func (m *Manager) Pop(r *http.Request, key string) interface{} {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	val, ok := sd.values[key]
+	if !ok {
+		return nil
+	}
+
+	delete(sd.values, key)
+	sd.status = modified
+	return val
+}
+
+// Remove deletes the value for the key from the session.
+func (m *Manager) Remove(r *http.Request, key string) {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if _, ok := sd.values[key]; !ok {
+		return
+	}
+
+	delete(sd.values, key)
+	sd.status = modified
+}
+
+// Exists reports whether the session has a value for the key.
+func (m *Manager) Exists(r *http.Request, key string) bool {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	_, ok := sd.values[key]
+	return ok
+}
+
+// Typed helpers return the zero value, if there is no value for the key
+// or it has a different type.
+
+func (m *Manager) GetString(r *http.Request, key string) string {
+	s, _ := m.Get(r, key).(string)
+	return s
+}
+
+func (m *Manager) GetInt(r *http.Request, key string) int {
+	i, _ := m.Get(r, key).(int)
+	return i
+}
+
+func (m *Manager) GetBool(r *http.Request, key string) bool {
+	b, _ := m.Get(r, key).(bool)
+	return b
+}
+
+func (m *Manager) PopString(r *http.Request, key string) string {
+	s, _ := m.Pop(r, key).(string)
+	return s
+}
+
+func (m *Manager) PopInt(r *http.Request, key string) int {
+	i, _ := m.Pop(r, key).(int)
+	return i
+}
+
+// RenewToken replaces the session token, keeping the values. It must be called
+// when the privilege level changes (login, logout) to prevent session fixation.
+func (m *Manager) RenewToken(r *http.Request) error {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.token != "" {
+		err := m.Store.Delete(sd.token)
+		if err != nil {
+			return err
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	sd.token = token
+	sd.status = modified
+	return nil
+}
+
+// Destroy deletes the session from the store and removes the cookie.
+// Values put after Destroy are saved in a new session.
+func (m *Manager) Destroy(r *http.Request) error {
+	sd := m.data(r)
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.token != "" {
+		err := m.Store.Delete(sd.token)
+		if err != nil {
+			return err
+		}
+	}
+
+	sd.token = ""
+	sd.deadline = time.Now().Add(m.Lifetime)
+	sd.values = make(map[string]interface{})
+	sd.status = destroyed
+	return nil
+}
+
+func (m *Manager) data(r *http.Request) *sessionData {
+	sd, ok := r.Context().Value(sessionContextKey).(*sessionData)
+	if !ok {
+		panic("session: no session data in request context, is the handler wrapped with Enable?")
+	}
+	return sd
+}
+
+// Commits the session right before the headers are written.
+type sessionWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	manager *Manager
+	data    *sessionData
+	written bool
+	failed  bool // The session commit failed, the handler's response is discarded.
+}
+
+func (sw *sessionWriter) WriteHeader(code int) {
+	if sw.written {
+		return
+	}
+	sw.written = true
+
+	err := sw.manager.commit(sw.ResponseWriter, sw.data)
+	if err != nil {
+		sw.failed = true
+		sw.manager.ErrorFunc(sw.ResponseWriter, sw.request, err)
+		return
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	if !sw.written {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.failed {
+		return len(b), nil
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Returns a random URL-safe session token (256 bits).
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,168 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Serves the request through the manager, and returns the session cookie set
+// by the response (nil if there is none).
+func serve(t *testing.T, m *Manager, cookie *http.Cookie, h http.HandlerFunc) *http.Cookie {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+
+	m.Enable(h).ServeHTTP(w, r)
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == m.Cookie.Name {
+			return c
+		}
+	}
+	return nil
+}
+
+func noop(w http.ResponseWriter, r *http.Request) {}
+
+func TestManager(t *testing.T) {
+	store := NewMemoryStore(0)
+	m := New(store)
+
+	// Anonymous visitors without values don't get a session.
+	if c := serve(t, m, nil, noop); c != nil {
+		t.Fatalf("got cookie %q for an empty session", c.Value)
+	}
+	if len(store.items) != 0 {
+		t.Fatalf("got %d stored sessions; want 0", len(store.items))
+	}
+
+	cookie := serve(t, m, nil, func(w http.ResponseWriter, r *http.Request) {
+		m.Put(r, "userID", 42)
+		m.Put(r, "flash", "Welcome!")
+	})
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("no session cookie after Put")
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie attributes: HttpOnly %v, SameSite %v", cookie.HttpOnly, cookie.SameSite)
+	}
+
+	serve(t, m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		if got := m.GetInt(r, "userID"); got != 42 {
+			t.Errorf("GetInt(userID) = %d; want 42", got)
+		}
+		if got := m.GetString(r, "userID"); got != "" {
+			t.Errorf("GetString of an int = %q; want the zero value", got)
+		}
+		if got := m.PopString(r, "flash"); got != "Welcome!" {
+			t.Errorf("PopString(flash) = %q; want Welcome!", got)
+		}
+	})
+
+	serve(t, m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		if m.Exists(r, "flash") {
+			t.Error("the popped value is still in the session")
+		}
+	})
+
+	// A new token keeps the values, and the old one stops working.
+	renewed := serve(t, m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		if err := m.RenewToken(r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if renewed == nil || renewed.Value == cookie.Value {
+		t.Fatal("the token isn't renewed")
+	}
+	serve(t, m, cookie, func(w http.ResponseWriter, r *http.Request) {
+		if m.Exists(r, "userID") {
+			t.Error("the old token still loads the session")
+		}
+	})
+	serve(t, m, renewed, func(w http.ResponseWriter, r *http.Request) {
+		if got := m.GetInt(r, "userID"); got != 42 {
+			t.Errorf("GetInt(userID) after RenewToken = %d; want 42", got)
+		}
+	})
+
+	destroyed := serve(t, m, renewed, func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Destroy(r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if destroyed == nil || destroyed.MaxAge >= 0 {
+		t.Errorf("the cookie isn't removed by Destroy: %v", destroyed)
+	}
+	if _, found, _ := store.Find(renewed.Value); found {
+		t.Error("the destroyed session is still stored")
+	}
+}
+
+func TestManagerExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		lifetime    time.Duration
+		idleTimeout time.Duration
+		wantFound   bool
+	}{
+		{"valid", time.Hour, time.Hour, true},
+		{"lifetime passed", 20 * time.Millisecond, 0, false},
+		{"idle timeout passed", time.Hour, 20 * time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(NewMemoryStore(0))
+			m.Lifetime = tt.lifetime
+			m.IdleTimeout = tt.idleTimeout
+
+			cookie := serve(t, m, nil, func(w http.ResponseWriter, r *http.Request) {
+				m.Put(r, "userID", 42)
+			})
+			if cookie == nil {
+				t.Fatal("no session cookie after Put")
+			}
+
+			time.Sleep(30 * time.Millisecond)
+
+			serve(t, m, cookie, func(w http.ResponseWriter, r *http.Request) {
+				if found := m.Exists(r, "userID"); found != tt.wantFound {
+					t.Errorf("session found %v; want %v", found, tt.wantFound)
+				}
+			})
+		})
+	}
+}
+
+func TestManagerLoad(t *testing.T) {
+	m := New(NewMemoryStore(0))
+
+	cookie := serve(t, m, nil, func(w http.ResponseWriter, r *http.Request) {
+		m.Put(r, "userID", 42)
+	})
+
+	// The session loaded by an outer middleware is read there, and reused by Enable.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookie)
+	r, err := m.Load(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetInt(r, "userID"); got != 42 {
+		t.Errorf("GetInt(userID) after Load = %d; want 42", got)
+	}
+
+	m.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Put(r, "flash", "Welcome!")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if got := m.GetString(r, "flash"); got != "Welcome!" {
+		t.Errorf("the value put by the handler isn't in the loaded session: %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/ol-ilyassov/test/internal/sqldialect"
+	"time"
+)
+
+// SQLStore keeps the sessions in the "sessions" table (see the migrations).
+type SQLStore struct {
+	db     *sql.DB
+	driver string // mysql|postgres|sqlite
+	stop   chan struct{}
+}
+
+// NewSQLStore returns the store, which removes the expired sessions
+// every cleanupInterval (0 disables the cleanup).
+func NewSQLStore(db *sql.DB, driver string, cleanupInterval time.Duration) *SQLStore {
+	s := &SQLStore{
+		db:     db,
+		driver: driver,
+		stop:   make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go cleanup(s.deleteExpired, cleanupInterval, s.stop)
+	}
+	return s
+}
+
+func (s *SQLStore) Find(token string) ([]byte, bool, error) {
+	query := `
+		SELECT data
+		FROM sessions
+		WHERE token = ? AND expiry > ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var b []byte
+
+	err := s.db.QueryRowContext(ctx, sqldialect.Rebind(s.driver, query), token, time.Now().UTC()).Scan(&b)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, false, nil
+		default:
+			return nil, false, err
+		}
+	}
+	return b, true, nil
+}
+
+func (s *SQLStore) Commit(token string, b []byte, expiry time.Time) error {
+	query := `
+		INSERT INTO sessions (token, data, expiry)
+		VALUES (?, ?, ?)
+		ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry`
+
+	if s.driver == "mysql" {
+		query = `
+			INSERT INTO sessions (token, data, expiry)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE data = VALUES(data), expiry = VALUES(expiry)`
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, sqldialect.Rebind(s.driver, query), token, b, expiry.UTC())
+	return err
+}
+
+func (s *SQLStore) Delete(token string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE token = ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, sqldialect.Rebind(s.driver, query), token)
+	return err
+}
+
+// StopCleanup stops the background cleanup of the expired sessions.
+func (s *SQLStore) StopCleanup() {
+	close(s.stop)
+}
+
+func (s *SQLStore) deleteExpired() error {
+	query := `
+		DELETE FROM sessions
+		WHERE expiry <= ?`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, sqldialect.Rebind(s.driver, query), time.Now().UTC())
+	return err
+}
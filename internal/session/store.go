@@ -0,0 +1,102 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps the serialized sessions by their tokens.
+type Store interface {
+	// Find returns the data of the session. Found is false, if the token
+	// doesn't exist or the session has expired.
+	Find(token string) (b []byte, found bool, err error)
+	// Commit adds the session, or replaces the data and the expiry of the existing one.
+	Commit(token string, b []byte, expiry time.Time) error
+	Delete(token string) error
+}
+
+// MemoryStore keeps the sessions in memory, they are lost when the process exits.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+	stop  chan struct{}
+}
+
+type memoryItem struct {
+	data   []byte
+	expiry time.Time
+}
+
+// NewMemoryStore returns the store, which removes the expired sessions
+// every cleanupInterval (0 disables the cleanup).
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		items: make(map[string]memoryItem),
+		stop:  make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go cleanup(s.deleteExpired, cleanupInterval, s.stop)
+	}
+	return s
+}
+
+func (s *MemoryStore) Find(token string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[token]
+	if !ok || !item.expiry.After(time.Now()) {
+		return nil, false, nil
+	}
+	return item.data, true, nil
+}
+
+func (s *MemoryStore) Commit(token string, b []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[token] = memoryItem{data: b, expiry: expiry}
+	return nil
+}
+
+func (s *MemoryStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, token)
+	return nil
+}
+
+// StopCleanup stops the background cleanup of the expired sessions.
+func (s *MemoryStore) StopCleanup() {
+	close(s.stop)
+}
+
+func (s *MemoryStore) deleteExpired() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for token, item := range s.items {
+		if !item.expiry.After(now) {
+			delete(s.items, token)
+		}
+	}
+	return nil
+}
+
+// Calls deleteExpired every interval, until stop is closed. Errors are ignored,
+// the expired sessions are never returned by Find anyway.
+func cleanup(deleteExpired func() error, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deleteExpired()
+		case <-stop:
+			return
+		}
+	}
+}
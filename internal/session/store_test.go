@@ -0,0 +1,91 @@
+package session
+
+import (
+	"bytes"
+	"database/sql"
+	_ "github.com/glebarez/go-sqlite"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLStore(t *testing.T) *SQLStore {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE sessions (token TEXT PRIMARY KEY, data BLOB NOT NULL, expiry DATETIME NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLStore(db, "sqlite", 0)
+}
+
+// Both stores remove the expired sessions with deleteExpired.
+type cleanableStore interface {
+	Store
+	deleteExpired() error
+}
+
+func TestStores(t *testing.T) {
+	stores := []struct {
+		name  string
+		store func(t *testing.T) cleanableStore
+	}{
+		{"memory", func(t *testing.T) cleanableStore { return NewMemoryStore(0) }},
+		{"sql", func(t *testing.T) cleanableStore { return newTestSQLStore(t) }},
+	}
+
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			store := st.store(t)
+
+			_, found, err := store.Find("missing")
+			if err != nil || found {
+				t.Fatalf("Find(missing) = %v, %v; want not found", found, err)
+			}
+
+			err = store.Commit("a", []byte("one"), time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Replaces the data.
+			err = store.Commit("a", []byte("two"), time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, found, err := store.Find("a")
+			if err != nil || !found || !bytes.Equal(b, []byte("two")) {
+				t.Fatalf("Find(a) = %q, %v, %v; want two", b, found, err)
+			}
+
+			// Expired sessions are not found, and are removed by the cleanup.
+			err = store.Commit("b", []byte("old"), time.Now().Add(-time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, found, _ := store.Find("b"); found {
+				t.Error("the expired session is found")
+			}
+			err = store.deleteExpired()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, found, _ := store.Find("a"); !found {
+				t.Error("the cleanup removed the valid session")
+			}
+
+			err = store.Delete("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, found, _ := store.Find("a"); found {
+				t.Error("the deleted session is found")
+			}
+		})
+	}
+}
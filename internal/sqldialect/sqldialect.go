@@ -0,0 +1,28 @@
+package sqldialect
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rebind rewrites the "?" placeholders of the query to the bind variables of
+// the driver: "$1", "$2", ... for PostgreSQL, the other drivers use "?" as is.
+// Question marks inside string literals are not supported, queries pass all
+// values as arguments.
+func Rebind(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
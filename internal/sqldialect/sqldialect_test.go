@@ -0,0 +1,25 @@
+package sqldialect
+
+import (
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		driver string
+		query  string
+		want   string
+	}{
+		{"postgres", "SELECT * FROM users WHERE id = ? AND email = ?", "SELECT * FROM users WHERE id = $1 AND email = $2"},
+		{"postgres", "SELECT 1", "SELECT 1"},
+		{"postgres", "UPDATE events SET title = ? WHERE title LIKE ? ESCAPE '!'", "UPDATE events SET title = $1 WHERE title LIKE $2 ESCAPE '!'"},
+		{"mysql", "SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = ?"},
+		{"sqlite", "SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = ?"},
+	}
+
+	for _, tt := range tests {
+		if got := Rebind(tt.driver, tt.query); got != tt.want {
+			t.Errorf("Rebind(%q, %q) = %q; want %q", tt.driver, tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package tracecontext
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceParent is the W3C trace context of a request (https://www.w3.org/TR/trace-context/):
+// the trace, which the request belongs to, and the span of the caller.
+type TraceParent struct {
+	TraceID  [16]byte
+	ParentID [8]byte
+	Flags    byte // Bit 0 is "sampled".
+}
+
+// Parse parses the traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". The versions after
+// 00 are parsed as 00, ignoring the fields they append, as the spec requires.
+func Parse(header string) (TraceParent, error) {
+	var tp TraceParent
+
+	header = strings.TrimSpace(header)
+	if len(header) < 55 || (len(header) > 55 && header[55] != '-') {
+		return tp, ErrInvalidTraceParent
+	}
+
+	parts := strings.Split(header[:55], "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return tp, ErrInvalidTraceParent
+	}
+
+	// Only the lowercase hex is valid.
+	for _, part := range parts {
+		if strings.ToLower(part) != part {
+			return tp, ErrInvalidTraceParent
+		}
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(header) != 55) {
+		return tp, ErrInvalidTraceParent
+	}
+
+	_, err1 := hex.Decode(tp.TraceID[:], []byte(parts[1]))
+	_, err2 := hex.Decode(tp.ParentID[:], []byte(parts[2]))
+	flags, err3 := hex.DecodeString(parts[3])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return tp, ErrInvalidTraceParent
+	}
+	tp.Flags = flags[0]
+
+	// All zeroes trace and parent IDs are invalid.
+	if tp.TraceID == [16]byte{} || tp.ParentID == [8]byte{} {
+		return tp, ErrInvalidTraceParent
+	}
+
+	return tp, nil
+}
+
+// New starts a new sampled trace.
+func New() (TraceParent, error) {
+	tp := TraceParent{Flags: 0x01}
+
+	_, err := rand.Read(tp.TraceID[:])
+	if err != nil {
+		return tp, err
+	}
+	_, err = rand.Read(tp.ParentID[:])
+	return tp, err
+}
+
+// Child returns the trace context of a new span in the same trace, e.g. of
+// the request handling, which is passed on to the next services.
+func (tp TraceParent) Child() (TraceParent, error) {
+	_, err := rand.Read(tp.ParentID[:])
+	return tp, err
+}
+
+// String returns the traceparent header value (version 00).
+func (tp TraceParent) String() string {
+	return "00-" + hex.EncodeToString(tp.TraceID[:]) + "-" + hex.EncodeToString(tp.ParentID[:]) + "-" + hex.EncodeToString([]byte{tp.Flags})
+}
+
+// TraceIDString returns the trace ID in hex.
+func (tp TraceParent) TraceIDString() string {
+	return hex.EncodeToString(tp.TraceID[:])
+}
+
+// SpanIDString returns the parent (span) ID in hex.
+func (tp TraceParent) SpanIDString() string {
+	return hex.EncodeToString(tp.ParentID[:])
+}
@@ -0,0 +1,96 @@
+package tracecontext
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"surrounding spaces", "  " + valid + " ", false},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false},
+		{"future version", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"future version with fields", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future", false},
+		{"version 00 with fields", valid + "-extra", true},
+		{"future version without a separator", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x", true},
+		{"forbidden version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"empty", "", true},
+		{"short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", true},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", true},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", true},
+		{"wrong separators", "00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", true},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true},
+		{"zero parent ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := Parse(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v; want error %v", tt.header, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := tp.TraceIDString(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("TraceIDString() = %q", got)
+			}
+			if got := tp.SpanIDString(); got != "00f067aa0ba902b7" {
+				t.Errorf("SpanIDString() = %q", got)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tp, err := Parse(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tp.String(); got != header {
+		t.Errorf("String() = %q; want %q", got, header)
+	}
+
+	// The newer versions are written as 00.
+	tp, err = Parse("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tp.String(); got != header {
+		t.Errorf("String() = %q; want %q", got, header)
+	}
+}
+
+func TestChild(t *testing.T) {
+	tp, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.Flags != 0x01 {
+		t.Errorf("the new trace isn't sampled: flags %02x", tp.Flags)
+	}
+
+	child, err := tp.Child()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.TraceID != tp.TraceID {
+		t.Error("the child span is in another trace")
+	}
+	if child.ParentID == tp.ParentID {
+		t.Error("the child span has the parent's ID")
+	}
+
+	// The written context parses back.
+	parsed, err := Parse(child.String())
+	if err != nil || parsed != child {
+		t.Errorf("Parse(String()) = %v, %v; want %v", parsed, err, child)
+	}
+}
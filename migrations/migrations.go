@@ -0,0 +1,9 @@
+// Package migrations embeds the versioned SQL schema migrations.
+// Each dialect has its own directory with "NNNNNN_name.up.sql" and
+// "NNNNNN_name.down.sql" file pairs.
+package migrations
+
+import "embed"
+
+//go:embed mysql postgres sqlite
+var FS embed.FS
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL,
+    name VARCHAR(500) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password_hash VARBINARY(60) NOT NULL,
+    activated BOOLEAN NOT NULL DEFAULT FALSE,
+    version INT NOT NULL DEFAULT 1,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
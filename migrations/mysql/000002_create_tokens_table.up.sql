@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS tokens (
+    hash VARBINARY(32) NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    expiry DATETIME NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    CONSTRAINT tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
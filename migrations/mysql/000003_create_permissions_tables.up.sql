@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(64) NOT NULL,
+    CONSTRAINT permissions_code_key UNIQUE (code)
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions (
+    user_id BIGINT NOT NULL,
+    permission_id BIGINT NOT NULL,
+    PRIMARY KEY (user_id, permission_id),
+    CONSTRAINT users_permissions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT users_permissions_permission_id_fkey FOREIGN KEY (permission_id) REFERENCES permissions (id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (code)
+VALUES ('events:read'), ('events:write'), ('users:admin');
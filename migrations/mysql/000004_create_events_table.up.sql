@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS events (
+    event_id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    title VARCHAR(500) NOT NULL,
+    description TEXT NOT NULL,
+    icon_id BIGINT NOT NULL,
+    contacts_link BIGINT NOT NULL,
+    created_time DATETIME NOT NULL,
+    version INT NOT NULL DEFAULT 1
+);
+
+CREATE INDEX events_created_time_idx ON events (created_time);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    token CHAR(43) NOT NULL PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry DATETIME(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
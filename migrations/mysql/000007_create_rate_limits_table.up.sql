@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS rate_limits (
+    bucket VARCHAR(255) NOT NULL,
+    window_start BIGINT NOT NULL,
+    hits INT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    PRIMARY KEY (bucket, window_start)
+);
+
+CREATE INDEX rate_limits_expires_at_idx ON rate_limits (expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE email_outbox
+    DROP COLUMN request_id,
+    DROP COLUMN trace_id;
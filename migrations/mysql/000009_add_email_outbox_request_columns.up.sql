@@ -0,0 +1,3 @@
+ALTER TABLE email_outbox
+    ADD COLUMN request_id VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN trace_id VARCHAR(32) NOT NULL DEFAULT '';
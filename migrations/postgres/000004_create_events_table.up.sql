@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS events (
+    event_id bigserial PRIMARY KEY,
+    title text NOT NULL,
+    description text NOT NULL,
+    icon_id bigint NOT NULL,
+    contacts_link bigint NOT NULL,
+    created_time timestamp(0) with time zone NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS events_created_time_idx ON events (created_time);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    token text PRIMARY KEY,
+    data bytea NOT NULL,
+    expiry timestamp(6) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
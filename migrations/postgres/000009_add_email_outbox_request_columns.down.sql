@@ -0,0 +1,3 @@
+ALTER TABLE email_outbox
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS trace_id;
@@ -0,0 +1,3 @@
+ALTER TABLE email_outbox
+    ADD COLUMN IF NOT EXISTS request_id text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS trace_id text NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    password_hash BLOB NOT NULL,
+    activated BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
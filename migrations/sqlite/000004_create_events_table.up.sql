@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS events (
+    event_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    icon_id INTEGER NOT NULL,
+    contacts_link INTEGER NOT NULL,
+    created_time DATETIME NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS events_created_time_idx ON events (created_time);
@@ -0,0 +1,2 @@
+ALTER TABLE email_outbox DROP COLUMN request_id;
+ALTER TABLE email_outbox DROP COLUMN trace_id;
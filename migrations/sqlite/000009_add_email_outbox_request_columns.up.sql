@@ -0,0 +1,2 @@
+ALTER TABLE email_outbox ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE email_outbox ADD COLUMN trace_id TEXT NOT NULL DEFAULT '';